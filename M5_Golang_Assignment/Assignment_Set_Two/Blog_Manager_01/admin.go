@@ -0,0 +1,80 @@
+package main
+
+import (
+	db "blogmanager/config"
+	"blogmanager/repository"
+	"blogmanager/service"
+	"blogmanager/settings"
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const createAdminUsage = "usage: blogmanager [flags] create-admin [--promote] username"
+
+// runCreateAdmin implements the create-admin subcommand, the only way to make
+// an admin. It creates a new admin, whose password is read from
+// BLOG_ADMIN_PASSWORD or else the first line of stdin, or with --promote makes
+// an existing user one.
+func runCreateAdmin(cfg *settings.Config, args []string) error {
+	fs := flag.NewFlagSet("create-admin", flag.ContinueOnError)
+	promote := fs.Bool("promote", false, "make an existing user an admin")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New(createAdminUsage)
+	}
+	username := fs.Arg(0)
+
+	var password string
+	if !*promote {
+		var err error
+		if password, err = adminPassword(os.Stdin); err != nil {
+			return err
+		}
+	}
+
+	if err := db.InitializeDatabase(cfg.Database.Driver, cfg.DSN()); err != nil {
+		return err
+	}
+	defer db.DB.Close()
+	userService := service.NewUserService(repository.NewUserRepository(db.GetDB(), repository.Dialect(db.Driver)))
+
+	if *promote {
+		user, err := userService.PromoteToAdmin(username)
+		if err != nil {
+			return fmt.Errorf("failed to promote %q: %v", username, err)
+		}
+		fmt.Printf("%s (id %d) is now an admin\n", user.Username, user.ID)
+		return nil
+	}
+
+	user, err := userService.CreateAdmin(username, password)
+	if err != nil {
+		return fmt.Errorf("failed to create %q: %v", username, err)
+	}
+	fmt.Printf("created admin %s (id %d)\n", user.Username, user.ID)
+	return nil
+}
+
+// adminPassword reads the password of a new admin from BLOG_ADMIN_PASSWORD,
+// falling back to the first line of r so it stays out of the shell history.
+func adminPassword(r io.Reader) (string, error) {
+	if password := os.Getenv("BLOG_ADMIN_PASSWORD"); password != "" {
+		return password, nil
+	}
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("set BLOG_ADMIN_PASSWORD or pass the password on stdin")
+	}
+	return password, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	db "blogmanager/config"
+	"blogmanager/repository"
+	"blogmanager/service"
+	"blogmanager/settings"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+const (
+	exportUsage = "usage: blogmanager [flags] export --out posts.zip"
+	importUsage = "usage: blogmanager [flags] import [--conflict skip|overwrite|rename] posts.zip"
+)
+
+// runExport implements the export subcommand, which writes every blog to a
+// zip archive, or to stdout when --out is -.
+func runExport(cfg *settings.Config, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	out := fs.String("out", "", "archive to write, - for stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *out == "" || fs.NArg() > 0 {
+		return errors.New(exportUsage)
+	}
+
+	archiveService, err := openArchiveService(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.DB.Close()
+
+	if *out == "-" {
+		return archiveService.ExportArchive(os.Stdout)
+	}
+	file, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := archiveService.ExportArchive(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// runImport implements the import subcommand and prints what it did as
+// JSON. A running server may serve the blogs it replaced from its cache
+// until they expire.
+func runImport(cfg *settings.Config, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	conflict := fs.String("conflict", service.ConflictSkip, "what to do with blogs whose title is taken: skip, overwrite or rename")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New(importUsage)
+	}
+
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	archiveService, err := openArchiveService(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.DB.Close()
+
+	report, err := archiveService.ImportArchive(bytes.NewReader(data), int64(len(data)), *conflict)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("%d blogs could not be imported", len(report.Errors))
+	}
+	return nil
+}
+
+func openArchiveService(cfg *settings.Config) (*service.ArchiveService, error) {
+	if err := db.InitializeDatabase(cfg.Database.Driver, cfg.DSN()); err != nil {
+		return nil, err
+	}
+	dialect := repository.Dialect(db.Driver)
+	blogService := service.NewBlogService(repository.NewBlogRepository(db.GetDB(), dialect))
+	return service.NewArchiveService(blogService, repository.NewUserRepository(db.GetDB(), dialect)), nil
+}
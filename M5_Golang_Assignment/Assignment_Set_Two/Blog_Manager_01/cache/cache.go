@@ -0,0 +1,28 @@
+// Package cache holds short-lived copies of values that are expensive to
+// load, such as blogs read from the database.
+package cache
+
+import "time"
+
+// Cache stores values under string keys until they expire. Values are
+// stored as bytes so that callers never share them, and so that an
+// out-of-process store such as Redis can stand in for the in-process LRU.
+type Cache interface {
+	// Get returns the value stored under key, if it has not expired.
+	Get(key string) ([]byte, bool)
+	// Set stores value under key for ttl.
+	Set(key string, value []byte, ttl time.Duration)
+	Delete(key string)
+	// DeletePrefix deletes every key starting with prefix.
+	DeletePrefix(prefix string)
+	Stats() Stats
+}
+
+// Stats counts the lookups and evictions of a cache since it was created.
+// Expired entries count as misses, not evictions.
+type Stats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Entries   int
+}
@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LRU is an in-process Cache holding up to Size entries. When it is full,
+// the least recently used entry makes room for a new one.
+type LRU struct {
+	Size int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // most recently used first
+	stats   Stats
+}
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+var _ Cache = (*LRU)(nil)
+
+func NewLRU(size int) *LRU {
+	return &LRU{Size: size, entries: make(map[string]*list.Element), order: list.New()}
+}
+
+func (lru *LRU) Get(key string) ([]byte, bool) {
+	lru.mu.Lock()
+	defer lru.mu.Unlock()
+
+	element, ok := lru.entries[key]
+	if !ok {
+		lru.stats.Misses++
+		return nil, false
+	}
+	entry := element.Value.(*lruEntry)
+	if time.Now().After(entry.expires) {
+		lru.remove(element)
+		lru.stats.Misses++
+		return nil, false
+	}
+	lru.order.MoveToFront(element)
+	lru.stats.Hits++
+	return entry.value, true
+}
+
+func (lru *LRU) Set(key string, value []byte, ttl time.Duration) {
+	lru.mu.Lock()
+	defer lru.mu.Unlock()
+
+	entry := &lruEntry{key: key, value: value, expires: time.Now().Add(ttl)}
+	if element, ok := lru.entries[key]; ok {
+		element.Value = entry
+		lru.order.MoveToFront(element)
+		return
+	}
+	lru.entries[key] = lru.order.PushFront(entry)
+	for lru.order.Len() > lru.Size {
+		lru.remove(lru.order.Back())
+		lru.stats.Evictions++
+	}
+}
+
+func (lru *LRU) Delete(key string) {
+	lru.mu.Lock()
+	defer lru.mu.Unlock()
+
+	if element, ok := lru.entries[key]; ok {
+		lru.remove(element)
+	}
+}
+
+func (lru *LRU) DeletePrefix(prefix string) {
+	lru.mu.Lock()
+	defer lru.mu.Unlock()
+
+	for key, element := range lru.entries {
+		if strings.HasPrefix(key, prefix) {
+			lru.remove(element)
+		}
+	}
+}
+
+func (lru *LRU) Stats() Stats {
+	lru.mu.Lock()
+	defer lru.mu.Unlock()
+
+	stats := lru.stats
+	stats.Entries = lru.order.Len()
+	return stats
+}
+
+func (lru *LRU) remove(element *list.Element) {
+	lru.order.Remove(element)
+	delete(lru.entries, element.Value.(*lruEntry).key)
+}
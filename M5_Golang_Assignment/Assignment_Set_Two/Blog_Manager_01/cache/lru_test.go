@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	lru := NewLRU(2)
+	lru.Set("a", []byte("1"), time.Minute)
+	lru.Set("b", []byte("2"), time.Minute)
+	// Reading a makes b the least recently used
+	if value, ok := lru.Get("a"); !ok || string(value) != "1" {
+		t.Fatalf("Get(a) = %q, %v", value, ok)
+	}
+	lru.Set("c", []byte("3"), time.Minute)
+
+	if _, ok := lru.Get("b"); ok {
+		t.Error("b was kept over a more recently used entry")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := lru.Get(key); !ok {
+			t.Errorf("%s was evicted", key)
+		}
+	}
+
+	// Replacing a value neither grows the cache nor evicts anything
+	lru.Set("c", []byte("4"), time.Minute)
+	if value, _ := lru.Get("c"); string(value) != "4" {
+		t.Errorf("Get(c) = %q after replacing it", value)
+	}
+	want := Stats{Hits: 4, Misses: 1, Evictions: 1, Entries: 2}
+	if stats := lru.Stats(); stats != want {
+		t.Errorf("Stats = %+v, want %+v", stats, want)
+	}
+}
+
+func TestLRUExpires(t *testing.T) {
+	lru := NewLRU(10)
+	lru.Set("short", []byte("1"), time.Millisecond)
+	lru.Set("long", []byte("2"), time.Minute)
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok := lru.Get("short"); ok {
+		t.Error("expired entry was returned")
+	}
+	if _, ok := lru.Get("long"); !ok {
+		t.Error("live entry was not returned")
+	}
+	// Expired entries are dropped as misses, not evictions
+	want := Stats{Hits: 1, Misses: 1, Entries: 1}
+	if stats := lru.Stats(); stats != want {
+		t.Errorf("Stats = %+v, want %+v", stats, want)
+	}
+}
+
+func TestLRUDelete(t *testing.T) {
+	lru := NewLRU(10)
+	for _, key := range []string{"blog:1", "blog:2", "blogs:page", "user:1"} {
+		lru.Set(key, []byte(key), time.Minute)
+	}
+	lru.Delete("user:1")
+	lru.Delete("missing")
+	lru.DeletePrefix("blog:")
+
+	for key, want := range map[string]bool{"blog:1": false, "blog:2": false, "blogs:page": true, "user:1": false} {
+		if _, ok := lru.Get(key); ok != want {
+			t.Errorf("%s cached = %v, want %v", key, ok, want)
+		}
+	}
+	if entries := lru.Stats().Entries; entries != 1 {
+		t.Errorf("%d entries left, want 1", entries)
+	}
+}
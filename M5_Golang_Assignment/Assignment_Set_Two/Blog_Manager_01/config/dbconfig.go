@@ -1,18 +1,34 @@
 package db
 
 import (
+	"blogmanager/repository"
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"strings"
 
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 )
 
 var DB *sql.DB
 
-func InitializeDatabase() error {
+// ErrNotInitialized is returned by checks run before the database is open.
+var ErrNotInitialized = errors.New("database not initialized")
+
+// Driver is the database/sql driver DB was opened with, sqlite3 or postgres.
+var Driver string
+
+// OpenDatabase connects to the blogs database without touching its schema.
+func OpenDatabase(driver, dsn string) error {
+	if _, err := repository.DialectFor(driver); err != nil {
+		return err
+	}
+
 	var err error
-	DB, err = sql.Open("sqlite3", "./blogs.db")
+	DB, err = sql.Open(driver, dsn)
 	if err != nil {
 		return fmt.Errorf("failed to connect to database: %v", err)
 	}
@@ -21,26 +37,87 @@ func InitializeDatabase() error {
 	if err := DB.Ping(); err != nil {
 		return fmt.Errorf("database connection failed: %v", err)
 	}
+	Driver = driver
+	return nil
+}
 
-	// Create Products table if not exists
-	_, err = DB.Exec(`CREATE TABLE IF NOT EXISTS blogs (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		title TEXT NOT NULL ,
-		content TEXT NOT NULL,
-		author TEXT NOT NULL,
-		timestamp TEXT NOT NULL
-	);`)
-	if err != nil {
-		return fmt.Errorf("failed to create blogs table: %v", err)
+// InitializeDatabase connects to the blogs database and applies any pending
+// migrations.
+func InitializeDatabase(driver, dsn string) error {
+	if err := OpenDatabase(driver, dsn); err != nil {
+		return err
 	}
 
-	log.Println("Successfully connected to the blogs database and ensured the schema exists.")
+	if _, err := Migrator().Up(); err != nil {
+		return fmt.Errorf("failed to migrate database: %v", err)
+	}
+
+	// PostgreSQL's search index is part of the migrations
+	if Driver == string(repository.SQLite) {
+		if err := initializeSearchIndex(); err != nil {
+			return fmt.Errorf("failed to create search index: %v", err)
+		}
+	}
+
+	slog.Info("connected to the blogs database and applied all migrations", "driver", driver)
 	return nil
 }
 
-func GetDB() *sql.DB {
+// Ping checks that the database answers before the deadline of ctx.
+func Ping(ctx context.Context) error {
 	if DB == nil {
-		log.Fatal("Database not initialized.")
+		return ErrNotInitialized
 	}
+	return DB.PingContext(ctx)
+}
+
+// GetDB returns the database opened by OpenDatabase, nil until then.
+func GetDB() *sql.DB {
 	return DB
 }
+
+// initializeSearchIndex creates the blogs_fts FTS5 index over blog titles and
+// content and the triggers that keep it in sync with the blogs table. FTS5 is
+// only compiled into go-sqlite3 with the sqlite_fts5 build tag; without it
+// searches fall back to slower LIKE matching.
+func initializeSearchIndex() error {
+	var exists int
+	err := DB.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'blogs_fts'").Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec(`CREATE VIRTUAL TABLE IF NOT EXISTS blogs_fts USING fts5(
+		title, content, content='blogs', content_rowid='id'
+	);`)
+	if err != nil {
+		if strings.Contains(err.Error(), "no such module: fts5") {
+			slog.Warn("SQLite was built without FTS5, searching without an index (build with -tags sqlite_fts5)")
+			return nil
+		}
+		return err
+	}
+
+	_, err = DB.Exec(`
+	CREATE TRIGGER IF NOT EXISTS blogs_fts_insert AFTER INSERT ON blogs BEGIN
+		INSERT INTO blogs_fts(rowid, title, content) VALUES (new.id, new.title, new.content);
+	END;
+	CREATE TRIGGER IF NOT EXISTS blogs_fts_delete AFTER DELETE ON blogs BEGIN
+		INSERT INTO blogs_fts(blogs_fts, rowid, title, content) VALUES ('delete', old.id, old.title, old.content);
+	END;
+	CREATE TRIGGER IF NOT EXISTS blogs_fts_update AFTER UPDATE OF title, content ON blogs BEGIN
+		INSERT INTO blogs_fts(blogs_fts, rowid, title, content) VALUES ('delete', old.id, old.title, old.content);
+		INSERT INTO blogs_fts(rowid, title, content) VALUES (new.id, new.title, new.content);
+	END;`)
+	if err != nil {
+		return err
+	}
+
+	// Index the blogs that were written before the index existed
+	if exists == 0 {
+		if _, err := DB.Exec("INSERT INTO blogs_fts(blogs_fts) VALUES ('rebuild')"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
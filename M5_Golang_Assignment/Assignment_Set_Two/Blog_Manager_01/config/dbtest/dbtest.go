@@ -0,0 +1,40 @@
+// Package dbtest opens migrated databases for tests. Tests of the repository
+// package use it from outside the package, as config imports repository.
+package dbtest
+
+import (
+	db "blogmanager/config"
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+// Open returns a migrated SQLite database in a temporary directory, closed
+// when the test ends.
+func Open(t testing.TB) *sql.DB {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "blogs.db") + "?_foreign_keys=on"
+	if err := db.InitializeDatabase("sqlite3", dsn); err != nil {
+		t.Fatal(err)
+	}
+	database := db.DB
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+// OpenPostgres migrates the PostgreSQL database at dsn and empties it.
+func OpenPostgres(t testing.TB, dsn string) *sql.DB {
+	t.Helper()
+	if err := db.InitializeDatabase("postgres", dsn); err != nil {
+		t.Fatal(err)
+	}
+	database := db.DB
+	t.Cleanup(func() { database.Close() })
+
+	_, err := database.Exec(`TRUNCATE users, blogs, comments, tags, blog_tags, blog_revisions, attachments
+		RESTART IDENTITY CASCADE`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return database
+}
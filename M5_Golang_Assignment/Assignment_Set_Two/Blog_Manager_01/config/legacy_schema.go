@@ -0,0 +1,267 @@
+package db
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// The functions in this file upgrade databases created before versioned
+// migrations, whose schema was patched in place at every startup, to the
+// initial migration.
+
+// createUsersTable matches the users table of the initial migration.
+const createUsersTable = `CREATE TABLE IF NOT EXISTS users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		username TEXT NOT NULL UNIQUE,
+		password_hash TEXT NOT NULL,
+		is_admin INTEGER NOT NULL DEFAULT 0,
+		created_at TEXT NOT NULL
+	);`
+
+// upgradeLegacySchema converts what the initial migration's CREATE TABLE IF
+// NOT EXISTS statements cannot: hashed passwords, blog ownership, the
+// publishing and rendering columns and RFC 3339 timestamps.
+func upgradeLegacySchema() error {
+	if err := migrateLegacyUsers(); err != nil {
+		return fmt.Errorf("failed to migrate users table: %v", err)
+	}
+
+	if _, err := DB.Exec(createUsersTable); err != nil {
+		return fmt.Errorf("failed to create users table: %v", err)
+	}
+
+	if err := migrateOwnership(); err != nil {
+		return fmt.Errorf("failed to add blog ownership columns: %v", err)
+	}
+
+	if err := migrateTimestamps(); err != nil {
+		return fmt.Errorf("failed to migrate blog timestamps: %v", err)
+	}
+
+	// Blogs written before the publishing workflow existed were all live
+	if err := ensureColumn("blogs", "status", "TEXT NOT NULL DEFAULT 'published'"); err != nil {
+		return fmt.Errorf("failed to add blogs.status column: %v", err)
+	}
+	if err := ensureColumn("blogs", "publish_at", "TEXT"); err != nil {
+		return fmt.Errorf("failed to add blogs.publish_at column: %v", err)
+	}
+	if err := ensureColumn("blogs", "format", "TEXT NOT NULL DEFAULT 'markdown'"); err != nil {
+		return fmt.Errorf("failed to add blogs.format column: %v", err)
+	}
+	if err := ensureColumn("blogs", "rendered_html", "TEXT"); err != nil {
+		return fmt.Errorf("failed to add blogs.rendered_html column: %v", err)
+	}
+
+	slog.Info("upgraded a database created before versioned migrations")
+	return nil
+}
+
+// migrateLegacyUsers rebuilds a users table created before passwords were
+// hashed (username, password) into the current schema, bcrypt-hashing the
+// plaintext passwords on the way.
+func migrateLegacyUsers() error {
+	columns, err := tableColumns("users")
+	if err != nil {
+		return err
+	}
+	if len(columns) == 0 || columns["password_hash"] {
+		return nil
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT username, password FROM users WHERE username IS NOT NULL AND password IS NOT NULL")
+	if err != nil {
+		return err
+	}
+	type legacyUser struct{ username, password string }
+	var legacy []legacyUser
+	for rows.Next() {
+		var u legacyUser
+		if err := rows.Scan(&u.username, &u.password); err != nil {
+			rows.Close()
+			return err
+		}
+		legacy = append(legacy, u)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DROP TABLE users"); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(createUsersTable); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, u := range legacy {
+		hash, err := bcrypt.GenerateFromPassword([]byte(u.password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("INSERT OR IGNORE INTO users (username, password_hash, created_at) VALUES (?, ?, ?)",
+			u.username, string(hash), now); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	slog.Info("migrated legacy users to hashed passwords", "count", len(legacy))
+	return nil
+}
+
+// migrateOwnership adds the is_admin and author_id columns to databases
+// created before blogs were tied to user accounts, and links existing posts
+// to the user whose username matches their free-text author, if any. No one
+// is made an admin; that is left to the create-admin command.
+func migrateOwnership() error {
+	userColumns, err := tableColumns("users")
+	if err != nil {
+		return err
+	}
+	if !userColumns["is_admin"] {
+		if _, err := DB.Exec("ALTER TABLE users ADD COLUMN is_admin INTEGER NOT NULL DEFAULT 0"); err != nil {
+			return err
+		}
+	}
+
+	blogColumns, err := tableColumns("blogs")
+	if err != nil {
+		return err
+	}
+	if !blogColumns["author_id"] {
+		if _, err := DB.Exec("ALTER TABLE blogs ADD COLUMN author_id INTEGER REFERENCES users(id)"); err != nil {
+			return err
+		}
+		_, err := DB.Exec(`UPDATE blogs SET author_id = (
+			SELECT id FROM users WHERE users.username = TRIM(blogs.author)
+		) WHERE author_id IS NULL`)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// legacyTimestampLayout is the format of time.Time.String(), which blogs were
+// timestamped with before created_at and updated_at existed.
+const legacyTimestampLayout = "2006-01-02 15:04:05.999999999 -0700 MST"
+
+// migrateTimestamps replaces the free-form timestamp column of older
+// databases with RFC 3339 UTC created_at and updated_at columns. The legacy
+// timestamp was rewritten on every update, so it is the best guess for both;
+// values that do not parse fall back to the time of the migration.
+func migrateTimestamps() error {
+	columns, err := tableColumns("blogs")
+	if err != nil {
+		return err
+	}
+	if !columns["timestamp"] {
+		return nil
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT id, timestamp FROM blogs")
+	if err != nil {
+		return err
+	}
+	stamps := map[int]string{}
+	for rows.Next() {
+		var id int
+		var value string
+		if err := rows.Scan(&id, &value); err != nil {
+			rows.Close()
+			return err
+		}
+		stamps[id] = value
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, column := range []string{"created_at", "updated_at"} {
+		if columns[column] {
+			continue
+		}
+		if _, err := tx.Exec("ALTER TABLE blogs ADD COLUMN " + column + " TEXT NOT NULL DEFAULT ''"); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now().UTC()
+	for id, value := range stamps {
+		// Drop the monotonic clock reading, e.g. " m=+12.345678901"
+		value, _, _ = strings.Cut(value, " m=")
+		t, err := time.Parse(legacyTimestampLayout, value)
+		if err != nil {
+			slog.Warn("blog has an unreadable timestamp, using the current time", "blog_id", id, "timestamp", value)
+			t = now
+		}
+		stamp := t.UTC().Format(time.RFC3339)
+		if _, err := tx.Exec("UPDATE blogs SET created_at = ?, updated_at = ? WHERE id = ?", stamp, stamp, id); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec("ALTER TABLE blogs DROP COLUMN timestamp"); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	slog.Info("migrated blog timestamps to created_at and updated_at", "count", len(stamps))
+	return nil
+}
+
+// ensureColumn adds a column to a table created before the column existed.
+func ensureColumn(table, column, definition string) error {
+	columns, err := tableColumns(table)
+	if err != nil {
+		return err
+	}
+	if columns[column] {
+		return nil
+	}
+	_, err = DB.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+// tableColumns returns the set of column names of a table, or an empty set
+// when the table does not exist.
+func tableColumns(table string) (map[string]bool, error) {
+	rows, err := DB.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"blogmanager/repository"
+	"context"
+	"embed"
+	"fmt"
+	"servicekit/migrate"
+)
+
+// Migrations are numbered SQL files in the migrations directory of each
+// dialect, named NNNN_name.up.sql and NNNN_name.down.sql.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+var migrationDirs = map[string]string{
+	"sqlite3":  "migrations/sqlite",
+	"postgres": "migrations/postgres",
+}
+
+// Migrator returns the migrator of the blogs database, for the migrations of
+// the current driver.
+func Migrator() *migrate.Migrator {
+	m := migrate.New(DB, migrationFiles, migrationDirs[Driver])
+	m.Rebind = repository.Dialect(Driver).Rebind
+	if Driver == string(repository.SQLite) {
+		m.Prepare = prepareLegacyDatabase
+	}
+	return m
+}
+
+// prepareLegacyDatabase upgrades a SQLite database that already has blogs but
+// no schema_migrations table, which predates versioned migrations, to the
+// initial schema.
+func prepareLegacyDatabase() error {
+	columns, err := tableColumns("schema_migrations")
+	if err != nil {
+		return err
+	}
+	if len(columns) > 0 {
+		return nil
+	}
+
+	blogColumns, err := tableColumns("blogs")
+	if err != nil {
+		return err
+	}
+	if len(blogColumns) > 0 {
+		if err := upgradeLegacySchema(); err != nil {
+			return fmt.Errorf("failed to upgrade legacy schema: %v", err)
+		}
+	}
+	return nil
+}
+
+// CheckMigrations fails unless every known migration has been applied.
+func CheckMigrations(ctx context.Context) error {
+	if DB == nil {
+		return ErrNotInitialized
+	}
+	return Migrator().Check(ctx)
+}
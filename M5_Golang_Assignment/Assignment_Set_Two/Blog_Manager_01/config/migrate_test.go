@@ -0,0 +1,101 @@
+package db
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// pendingCount returns how many known migrations have not been applied.
+func pendingCount(t *testing.T) int {
+	t.Helper()
+	states, err := Migrator().Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending := 0
+	for _, state := range states {
+		if state.AppliedAt == nil {
+			pending++
+		}
+	}
+	return pending
+}
+
+func TestMigrations(t *testing.T) {
+	// Both dialects must have the same migrations, numbered without gaps
+	names := make(map[string][]string)
+	for _, driver := range []string{"sqlite3", "postgres"} {
+		Driver = driver
+		migrations, err := Migrator().Load()
+		if err != nil {
+			t.Fatalf("%s: %v", driver, err)
+		}
+		for i, m := range migrations {
+			if m.Version != i+1 {
+				t.Errorf("%s: migration %d is numbered %04d", driver, i+1, m.Version)
+			}
+			names[driver] = append(names[driver], m.Name)
+		}
+	}
+	if !slices.Equal(names["sqlite3"], names["postgres"]) {
+		t.Errorf("SQLite migrations %v differ from PostgreSQL ones %v", names["sqlite3"], names["postgres"])
+	}
+
+	if err := OpenDatabase("sqlite3", filepath.Join(t.TempDir(), "blogs.db")+"?_foreign_keys=on"); err != nil {
+		t.Fatal(err)
+	}
+	defer DB.Close()
+	migrator := Migrator()
+	migrations, err := migrator.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := len(migrations)
+
+	steps := []struct {
+		name        string
+		run         func() (int, error)
+		wantCount   int
+		wantPending int
+	}{
+		{"up", migrator.Up, total, 0},
+		{"up again", migrator.Up, 0, 0},
+		{"down one", func() (int, error) { return migrator.Down(1) }, 1, 1},
+		{"up after down", migrator.Up, 1, 0},
+		{"down all", func() (int, error) { return migrator.Down(total + 1) }, total, total},
+		{"down when empty", func() (int, error) { return migrator.Down(1) }, 0, total},
+		{"up from scratch", migrator.Up, total, 0},
+	}
+	for _, step := range steps {
+		count, err := step.run()
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if count != step.wantCount {
+			t.Errorf("%s: ran %d migrations, want %d", step.name, count, step.wantCount)
+		}
+		if pending := pendingCount(t); pending != step.wantPending {
+			t.Errorf("%s: %d migrations pending, want %d", step.name, pending, step.wantPending)
+		}
+		err = CheckMigrations(context.Background())
+		if (err == nil) != (step.wantPending == 0) {
+			t.Errorf("%s: CheckMigrations = %v with %d pending", step.name, err, step.wantPending)
+		}
+	}
+
+	// Reverting everything leaves only the bookkeeping table
+	if _, err := migrator.Down(total); err != nil {
+		t.Fatal(err)
+	}
+	var tables int
+	err = DB.QueryRow(`SELECT COUNT(*) FROM sqlite_master
+		WHERE type = 'table' AND name NOT IN ('schema_migrations', 'sqlite_sequence')`).Scan(&tables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tables != 0 {
+		t.Errorf("%d tables left after reverting every migration", tables)
+	}
+}
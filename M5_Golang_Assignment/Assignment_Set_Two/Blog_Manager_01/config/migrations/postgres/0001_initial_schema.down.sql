@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS blog_revisions;
+DROP TABLE IF EXISTS blog_tags;
+DROP TABLE IF EXISTS tags;
+DROP TABLE IF EXISTS comments;
+DROP TABLE IF EXISTS blogs;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,62 @@
+-- PostgreSQL version of the initial schema. Timestamps are RFC 3339 text and
+-- flags are integers, as in SQLite, so that both share the repositories.
+CREATE TABLE IF NOT EXISTS users (
+	id SERIAL PRIMARY KEY,
+	username TEXT NOT NULL UNIQUE,
+	password_hash TEXT NOT NULL,
+	is_admin INTEGER NOT NULL DEFAULT 0,
+	created_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS blogs (
+	id SERIAL PRIMARY KEY,
+	title TEXT NOT NULL,
+	content TEXT NOT NULL,
+	author TEXT NOT NULL,
+	created_at TEXT NOT NULL,
+	updated_at TEXT NOT NULL,
+	author_id INTEGER REFERENCES users(id),
+	status TEXT NOT NULL DEFAULT 'published',
+	publish_at TEXT,
+	format TEXT NOT NULL DEFAULT 'markdown',
+	rendered_html TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_blogs_status_publish_at ON blogs(status, publish_at);
+
+-- Full-text search, see searchDocument in the blog repository
+CREATE INDEX IF NOT EXISTS idx_blogs_search ON blogs USING GIN (
+	(setweight(to_tsvector('simple', title), 'A') || setweight(to_tsvector('simple', content), 'D'))
+);
+
+-- Comments and their replies go away with the blog or parent they belong to
+CREATE TABLE IF NOT EXISTS comments (
+	id SERIAL PRIMARY KEY,
+	blog_id INTEGER NOT NULL REFERENCES blogs(id) ON DELETE CASCADE,
+	parent_id INTEGER REFERENCES comments(id) ON DELETE CASCADE,
+	author TEXT NOT NULL,
+	author_id INTEGER NOT NULL REFERENCES users(id),
+	content TEXT NOT NULL,
+	created_at TEXT NOT NULL,
+	updated_at TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_comments_blog_id ON comments(blog_id);
+
+CREATE TABLE IF NOT EXISTS tags (
+	id SERIAL PRIMARY KEY,
+	name TEXT NOT NULL UNIQUE
+);
+CREATE TABLE IF NOT EXISTS blog_tags (
+	blog_id INTEGER NOT NULL REFERENCES blogs(id) ON DELETE CASCADE,
+	tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+	PRIMARY KEY (blog_id, tag_id)
+);
+CREATE INDEX IF NOT EXISTS idx_blog_tags_tag_id ON blog_tags(tag_id);
+
+CREATE TABLE IF NOT EXISTS blog_revisions (
+	blog_id INTEGER NOT NULL REFERENCES blogs(id) ON DELETE CASCADE,
+	revision INTEGER NOT NULL,
+	title TEXT NOT NULL,
+	content TEXT NOT NULL,
+	created_at TEXT NOT NULL,
+	PRIMARY KEY (blog_id, revision)
+);
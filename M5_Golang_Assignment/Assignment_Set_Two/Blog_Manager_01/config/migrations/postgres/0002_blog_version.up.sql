@@ -0,0 +1,3 @@
+-- Blogs carry a version that goes up with every change, for optimistic
+-- concurrency through ETag and If-Match.
+ALTER TABLE blogs ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
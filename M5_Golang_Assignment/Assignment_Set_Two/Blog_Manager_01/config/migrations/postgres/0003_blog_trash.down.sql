@@ -0,0 +1,4 @@
+-- Without the column trashed blogs would come back, so purge them first
+DELETE FROM blogs WHERE deleted_at IS NOT NULL;
+DROP INDEX IF EXISTS idx_blogs_deleted_at;
+ALTER TABLE blogs DROP COLUMN deleted_at;
@@ -0,0 +1,14 @@
+-- Files uploaded to blogs. The file itself is stored under the media
+-- directory, named by the SHA-256 hash of its content, so blogs sharing a
+-- file share one copy.
+CREATE TABLE IF NOT EXISTS attachments (
+	id SERIAL PRIMARY KEY,
+	blog_id INTEGER NOT NULL REFERENCES blogs(id) ON DELETE CASCADE,
+	hash TEXT NOT NULL,
+	filename TEXT NOT NULL,
+	content_type TEXT NOT NULL,
+	size BIGINT NOT NULL,
+	created_at TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_attachments_blog_id ON attachments (blog_id);
+CREATE INDEX IF NOT EXISTS idx_attachments_hash ON attachments (hash);
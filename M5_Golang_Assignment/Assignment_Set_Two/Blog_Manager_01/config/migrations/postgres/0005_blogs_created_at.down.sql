@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_blogs_created_at;
@@ -0,0 +1,2 @@
+ALTER TABLE blog_revisions DROP COLUMN tags;
+ALTER TABLE blog_revisions DROP COLUMN status;
@@ -0,0 +1,8 @@
+-- The search index is created outside of migrations, see initializeSearchIndex
+DROP TABLE IF EXISTS blogs_fts;
+DROP TABLE IF EXISTS blog_revisions;
+DROP TABLE IF EXISTS blog_tags;
+DROP TABLE IF EXISTS tags;
+DROP TABLE IF EXISTS comments;
+DROP TABLE IF EXISTS blogs;
+DROP TABLE IF EXISTS users;
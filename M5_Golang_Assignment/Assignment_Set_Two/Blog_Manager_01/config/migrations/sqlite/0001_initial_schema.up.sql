@@ -0,0 +1,58 @@
+-- Schema as of the introduction of versioned migrations. Databases created
+-- before then are upgraded to it by upgradeLegacySchema, so every statement
+-- must tolerate objects that already exist.
+CREATE TABLE IF NOT EXISTS users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	username TEXT NOT NULL UNIQUE,
+	password_hash TEXT NOT NULL,
+	is_admin INTEGER NOT NULL DEFAULT 0,
+	created_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS blogs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	title TEXT NOT NULL,
+	content TEXT NOT NULL,
+	author TEXT NOT NULL,
+	created_at TEXT NOT NULL,
+	updated_at TEXT NOT NULL,
+	author_id INTEGER REFERENCES users(id),
+	status TEXT NOT NULL DEFAULT 'published',
+	publish_at TEXT,
+	format TEXT NOT NULL DEFAULT 'markdown',
+	rendered_html TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_blogs_status_publish_at ON blogs(status, publish_at);
+
+-- Comments and their replies go away with the blog or parent they belong to
+CREATE TABLE IF NOT EXISTS comments (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	blog_id INTEGER NOT NULL REFERENCES blogs(id) ON DELETE CASCADE,
+	parent_id INTEGER REFERENCES comments(id) ON DELETE CASCADE,
+	author TEXT NOT NULL,
+	author_id INTEGER NOT NULL REFERENCES users(id),
+	content TEXT NOT NULL,
+	created_at TEXT NOT NULL,
+	updated_at TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_comments_blog_id ON comments(blog_id);
+
+CREATE TABLE IF NOT EXISTS tags (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL UNIQUE
+);
+CREATE TABLE IF NOT EXISTS blog_tags (
+	blog_id INTEGER NOT NULL REFERENCES blogs(id) ON DELETE CASCADE,
+	tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+	PRIMARY KEY (blog_id, tag_id)
+);
+CREATE INDEX IF NOT EXISTS idx_blog_tags_tag_id ON blog_tags(tag_id);
+
+CREATE TABLE IF NOT EXISTS blog_revisions (
+	blog_id INTEGER NOT NULL REFERENCES blogs(id) ON DELETE CASCADE,
+	revision INTEGER NOT NULL,
+	title TEXT NOT NULL,
+	content TEXT NOT NULL,
+	created_at TEXT NOT NULL,
+	PRIMARY KEY (blog_id, revision)
+);
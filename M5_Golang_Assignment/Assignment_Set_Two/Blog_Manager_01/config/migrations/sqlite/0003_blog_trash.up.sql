@@ -0,0 +1,4 @@
+-- Deleted blogs stay in the trash, with the time they were deleted, until
+-- they are restored or purged.
+ALTER TABLE blogs ADD COLUMN deleted_at TEXT;
+CREATE INDEX IF NOT EXISTS idx_blogs_deleted_at ON blogs (deleted_at);
@@ -0,0 +1,2 @@
+-- The newest and oldest listings are ordered and paginated by creation time.
+CREATE INDEX IF NOT EXISTS idx_blogs_created_at ON blogs (created_at, id);
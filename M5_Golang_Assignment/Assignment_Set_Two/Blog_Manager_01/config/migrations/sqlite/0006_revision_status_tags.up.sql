@@ -0,0 +1,4 @@
+-- Revisions also record the status and tags, as a JSON array, of the blog.
+-- Those saved before are left without them.
+ALTER TABLE blog_revisions ADD COLUMN status TEXT;
+ALTER TABLE blog_revisions ADD COLUMN tags TEXT;
@@ -0,0 +1,356 @@
+package controller
+
+import (
+	"blogmanager/config/dbtest"
+	"blogmanager/middleware"
+	"blogmanager/repository"
+	"blogmanager/service"
+	"blogmanager/storage"
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testMaxUploadSize is the attachment size limit of testAPI.
+const testMaxUploadSize = 1 << 10
+
+// testAPI serves the user, blog, comment, attachment and feed routes like
+// main does, over a SQLite database of its own.
+type testAPI struct {
+	t      *testing.T
+	router *gin.Engine
+	blogs  *service.BlogService
+}
+
+func newTestAPI(t *testing.T, requireIfMatch bool) *testAPI {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	database := dbtest.Open(t)
+	userRepo := repository.NewUserRepository(database, repository.SQLite)
+	userService := service.NewUserService(userRepo)
+	blogService := service.NewBlogService(repository.NewBlogRepository(database, repository.SQLite))
+	userController := NewUserController(userService)
+	blogController := NewBlogController(blogService, requireIfMatch)
+	feedController := NewFeedController(service.NewFeedService(blogService, userRepo))
+	mediaStorage, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	attachmentService := service.NewAttachmentService(repository.NewAttachmentRepository(database, repository.SQLite),
+		blogService.BlogStore, mediaStorage)
+	attachmentController := NewAttachmentController(attachmentService, testMaxUploadSize)
+	commentController := NewCommentController(service.NewCommentService(repository.NewCommentRepository(database, repository.SQLite),
+		blogService.BlogStore))
+
+	r := gin.New()
+	r.Use(middleware.RequestIDMiddleware())
+	r.POST("/api/register", userController.Register)
+	r.GET("/feed.rss", feedController.GetRSS)
+	r.GET("/media/:hash", middleware.AuthMiddleware(userService, true), attachmentController.ServeMedia)
+	api := r.Group("/api")
+	api.Use(middleware.AuthMiddleware(userService, false))
+	api.POST("/blog", blogController.CreateBlog)
+	api.GET("/blog/:id", blogController.GetBlog)
+	api.GET("/blog", blogController.GetAllBlogs)
+	api.PUT("/blog/:id", blogController.UpdateBlog)
+	api.DELETE("/blog/:id", blogController.DeleteBlog)
+	api.GET("/trash", blogController.GetTrash)
+	api.POST("/blog/:id/restore", blogController.RestoreBlog)
+	api.POST("/blog/:id/attachments", attachmentController.UploadAttachment)
+	api.GET("/blog/:id/comments", commentController.GetComments)
+	api.POST("/blog/:id/comments", commentController.CreateComment)
+	api.PUT("/comments/:id", commentController.UpdateComment)
+	api.DELETE("/comments/:id", commentController.DeleteComment)
+	return &testAPI{t: t, router: r, blogs: blogService}
+}
+
+// upload sends content as an attachment named filename of the blog at
+// path, as user.
+func (api *testAPI) upload(user, path, filename string, content []byte) *httptest.ResponseRecorder {
+	api.t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", filename)
+	if err != nil {
+		api.t.Fatal(err)
+	}
+	part.Write(content)
+	form.Close()
+
+	r := httptest.NewRequest(http.MethodPost, path+"/attachments", &body)
+	r.Header.Set("Content-Type", form.FormDataContentType())
+	r.SetBasicAuth(user, "password123")
+	w := httptest.NewRecorder()
+	api.router.ServeHTTP(w, r)
+	return w
+}
+
+// request is sent as user, whose password is password123, unless user is
+// empty. headers are pairs of names and values.
+type request struct {
+	method, path, user, body string
+	headers                  []string
+}
+
+func (api *testAPI) do(req request) *httptest.ResponseRecorder {
+	api.t.Helper()
+	r := httptest.NewRequest(req.method, req.path, strings.NewReader(req.body))
+	if req.body != "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	if req.user != "" {
+		r.SetBasicAuth(req.user, "password123")
+	}
+	for i := 0; i+1 < len(req.headers); i += 2 {
+		r.Header.Set(req.headers[i], req.headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	api.router.ServeHTTP(w, r)
+	return w
+}
+
+// expect sends req and fails the test unless it is answered with status.
+func (api *testAPI) expect(req request, status int) *httptest.ResponseRecorder {
+	api.t.Helper()
+	w := api.do(req)
+	if w.Code != status {
+		api.t.Fatalf("%s %s: status %d, want %d: %s", req.method, req.path, w.Code, status, w.Body)
+	}
+	return w
+}
+
+func (api *testAPI) register(username string) {
+	api.t.Helper()
+	api.expect(request{method: http.MethodPost, path: "/api/register",
+		body: `{"username":"` + username + `","password":"password123"}`}, http.StatusCreated)
+}
+
+// createBlog creates a blog as user and returns its path.
+func (api *testAPI) createBlog(user, title string) string {
+	api.t.Helper()
+	w := api.expect(request{method: http.MethodPost, path: "/api/blog", user: user,
+		body: `{"title":"` + title + `","content":"Content"}`}, http.StatusOK)
+	var blog struct {
+		ID int `json:"id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &blog); err != nil {
+		api.t.Fatal(err)
+	}
+	return "/api/blog/" + strconv.Itoa(blog.ID)
+}
+
+func TestRegisterAndAuthenticate(t *testing.T) {
+	api := newTestAPI(t, false)
+	w := api.expect(request{method: http.MethodPost, path: "/api/register",
+		body: `{"username":"alice","password":"password123"}`}, http.StatusCreated)
+	if body := w.Body.String(); strings.Contains(body, "password") || strings.Contains(body, "$2") {
+		t.Errorf("registration answered with the password hash: %s", body)
+	}
+	if strings.Contains(w.Body.String(), `"is_admin":true`) {
+		t.Errorf("registration granted admin: %s", w.Body)
+	}
+
+	api.expect(request{method: http.MethodPost, path: "/api/register",
+		body: `{"username":"alice","password":"password123"}`}, http.StatusConflict)
+	api.expect(request{method: http.MethodPost, path: "/api/register",
+		body: `{"username":"ali:ce","password":"password123"}`}, http.StatusBadRequest)
+	api.expect(request{method: http.MethodPost, path: "/api/register",
+		body: `{"username":"bob","password":"short"}`}, http.StatusBadRequest)
+
+	api.expect(request{method: http.MethodGet, path: "/api/blog"}, http.StatusUnauthorized)
+	api.expect(request{method: http.MethodGet, path: "/api/blog", user: "nobody"}, http.StatusUnauthorized)
+	wrong := request{method: http.MethodGet, path: "/api/blog", headers: []string{"Authorization", "Basic YWxpY2U6d3Jvbmc="}}
+	api.expect(wrong, http.StatusUnauthorized)
+	api.expect(request{method: http.MethodGet, path: "/api/blog", user: "alice"}, http.StatusOK)
+}
+
+func TestOnlyAuthorsChangeTheirBlogs(t *testing.T) {
+	api := newTestAPI(t, false)
+	api.register("alice")
+	api.register("bob")
+	path := api.createBlog("alice", "Alice's")
+
+	api.expect(request{method: http.MethodPut, path: path, user: "bob", body: `{"title":"Bob's","content":"Mine"}`}, http.StatusForbidden)
+	api.expect(request{method: http.MethodDelete, path: path, user: "bob"}, http.StatusForbidden)
+	w := api.expect(request{method: http.MethodGet, path: path, user: "bob"}, http.StatusOK)
+	if !strings.Contains(w.Body.String(), `"title":"Alice's"`) {
+		t.Errorf("blog changed by another user: %s", w.Body)
+	}
+	api.expect(request{method: http.MethodPut, path: path, user: "alice", body: `{"title":"Still Alice's","content":"Content"}`}, http.StatusOK)
+}
+
+func TestListingPages(t *testing.T) {
+	api := newTestAPI(t, false)
+	api.register("alice")
+	for _, title := range []string{"First", "Second", "Third"} {
+		api.createBlog("alice", title)
+	}
+
+	var titles []string
+	path := "/api/blog?limit=2&sort=oldest"
+	for pages := 0; path != ""; pages++ {
+		if pages == 3 {
+			t.Fatal("listing does not end")
+		}
+		w := api.expect(request{method: http.MethodGet, path: path, user: "alice"}, http.StatusOK)
+		var page struct {
+			Data []struct {
+				Title string `json:"title"`
+			} `json:"data"`
+			NextCursor string `json:"next_cursor"`
+			Total      int    `json:"total"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+			t.Fatal(err)
+		}
+		if page.Total != 3 {
+			t.Errorf("total = %d, want 3", page.Total)
+		}
+		for _, blog := range page.Data {
+			titles = append(titles, blog.Title)
+		}
+		path = ""
+		if page.NextCursor != "" {
+			path = "/api/blog?limit=2&sort=oldest&after=" + page.NextCursor
+		}
+	}
+	if got := strings.Join(titles, ","); got != "First,Second,Third" {
+		t.Errorf("listed %s, want First,Second,Third", got)
+	}
+	api.expect(request{method: http.MethodGet, path: "/api/blog?after=garbage", user: "alice"}, http.StatusBadRequest)
+}
+
+func TestFeedConditionalGet(t *testing.T) {
+	api := newTestAPI(t, false)
+	api.register("alice")
+	api.createBlog("alice", "Syndicated")
+
+	w := api.expect(request{method: http.MethodGet, path: "/feed.rss"}, http.StatusOK)
+	etag, lastModified := w.Header().Get("ETag"), w.Header().Get("Last-Modified")
+	if etag == "" || lastModified == "" || !strings.Contains(w.Body.String(), "Syndicated") {
+		t.Fatalf("feed: ETag %q, Last-Modified %q: %s", etag, lastModified, w.Body)
+	}
+
+	if w := api.expect(request{method: http.MethodGet, path: "/feed.rss", headers: []string{"If-None-Match", etag}}, http.StatusNotModified); w.Body.Len() != 0 {
+		t.Errorf("304 with a body: %s", w.Body)
+	}
+	api.expect(request{method: http.MethodGet, path: "/feed.rss", headers: []string{"If-Modified-Since", lastModified}}, http.StatusNotModified)
+	// If-None-Match wins over If-Modified-Since
+	api.expect(request{method: http.MethodGet, path: "/feed.rss",
+		headers: []string{"If-None-Match", `"stale"`, "If-Modified-Since", lastModified}}, http.StatusOK)
+
+	api.createBlog("alice", "Newer")
+	if w := api.expect(request{method: http.MethodGet, path: "/feed.rss", headers: []string{"If-None-Match", etag}}, http.StatusOK); !strings.Contains(w.Body.String(), "Newer") {
+		t.Errorf("feed lacks the new blog: %s", w.Body)
+	}
+}
+
+// TestFeedModifiedByPublishing checks that Last-Modified moves on when a
+// scheduled blog enters the feed without being edited.
+func TestFeedModifiedByPublishing(t *testing.T) {
+	api := newTestAPI(t, false)
+	api.register("alice")
+	api.createBlog("alice", "Syndicated")
+	publishAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	api.expect(request{method: http.MethodPost, path: "/api/blog", user: "alice",
+		body: `{"title":"Scheduled","content":"Content","status":"scheduled","publish_at":"` + publishAt + `"}`}, http.StatusOK)
+
+	w := api.expect(request{method: http.MethodGet, path: "/feed.rss"}, http.StatusOK)
+	lastModified := w.Header().Get("Last-Modified")
+	if strings.Contains(w.Body.String(), "Scheduled") {
+		t.Fatalf("feed lists a scheduled blog: %s", w.Body)
+	}
+
+	// The publisher runs once the blog is due
+	if n, err := api.blogs.BlogStore.PublishDueBlogs(time.Now().Add(2 * time.Hour)); err != nil || n != 1 {
+		t.Fatalf("PublishDueBlogs = %d, %v, want 1", n, err)
+	}
+	w = api.expect(request{method: http.MethodGet, path: "/feed.rss", headers: []string{"If-Modified-Since", lastModified}}, http.StatusOK)
+	if !strings.Contains(w.Body.String(), "Scheduled") {
+		t.Errorf("feed lacks the published blog: %s", w.Body)
+	}
+
+}
+
+func TestRequestID(t *testing.T) {
+	api := newTestAPI(t, false)
+	tests := []struct {
+		name, sent string
+		reused     bool
+	}{
+		{"none", "", false},
+		{"valid", "req-42.a_b", true},
+		{"injected", "id\nlevel=ERROR", false},
+		{"too long", strings.Repeat("a", 129), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := api.do(request{method: http.MethodGet, path: "/feed.rss", headers: []string{middleware.RequestIDHeader, tt.sent}})
+			got := w.Header().Get(middleware.RequestIDHeader)
+			if got == "" || (got == tt.sent) != tt.reused {
+				t.Errorf("X-Request-ID %q for %q", got, tt.sent)
+			}
+		})
+	}
+}
+
+func TestConditionalUpdates(t *testing.T) {
+	api := newTestAPI(t, true)
+	api.register("alice")
+	path := api.createBlog("alice", "Versioned")
+	update := `{"title":"Versioned","content":"New content"}`
+
+	etag := api.expect(request{method: http.MethodGet, path: path, user: "alice"}, http.StatusOK).Header().Get("ETag")
+	if etag != `"1"` {
+		t.Fatalf("ETag = %s, want \"1\"", etag)
+	}
+
+	api.expect(request{method: http.MethodPut, path: path, user: "alice", body: update}, http.StatusPreconditionRequired)
+	api.expect(request{method: http.MethodDelete, path: path, user: "alice"}, http.StatusPreconditionRequired)
+
+	w := api.expect(request{method: http.MethodPut, path: path, user: "alice", body: update,
+		headers: []string{"If-Match", etag}}, http.StatusOK)
+	if got := w.Header().Get("ETag"); got != `"2"` {
+		t.Errorf("ETag after the update = %s, want \"2\"", got)
+	}
+
+	// The stale ETag is refused, and the current one sent back
+	w = api.expect(request{method: http.MethodPut, path: path, user: "alice", body: update,
+		headers: []string{"If-Match", etag}}, http.StatusPreconditionFailed)
+	if got := w.Header().Get("ETag"); got != `"2"` {
+		t.Errorf("ETag of the refused update = %s, want \"2\"", got)
+	}
+	api.expect(request{method: http.MethodDelete, path: path, user: "alice", headers: []string{"If-Match", etag}}, http.StatusPreconditionFailed)
+	api.expect(request{method: http.MethodDelete, path: path, user: "alice", headers: []string{"If-Match", `"2"`}}, http.StatusOK)
+}
+
+func TestTrashAndRestore(t *testing.T) {
+	api := newTestAPI(t, false)
+	api.register("alice")
+	api.register("bob")
+	path := api.createBlog("alice", "Trashed")
+
+	api.expect(request{method: http.MethodDelete, path: path, user: "alice"}, http.StatusOK)
+	api.expect(request{method: http.MethodGet, path: path, user: "alice"}, http.StatusNotFound)
+	if w := api.expect(request{method: http.MethodGet, path: "/api/blog", user: "alice"}, http.StatusOK); strings.Contains(w.Body.String(), "Trashed") {
+		t.Errorf("trashed blog is listed: %s", w.Body)
+	}
+	if w := api.expect(request{method: http.MethodGet, path: "/api/trash", user: "alice"}, http.StatusOK); !strings.Contains(w.Body.String(), "Trashed") {
+		t.Errorf("trash lacks the blog: %s", w.Body)
+	}
+	if w := api.expect(request{method: http.MethodGet, path: "/api/trash", user: "bob"}, http.StatusOK); strings.Contains(w.Body.String(), "Trashed") {
+		t.Errorf("trash of another user holds the blog: %s", w.Body)
+	}
+
+	api.expect(request{method: http.MethodPost, path: path + "/restore", user: "bob"}, http.StatusForbidden)
+	api.expect(request{method: http.MethodPost, path: path + "/restore", user: "alice"}, http.StatusOK)
+	api.expect(request{method: http.MethodGet, path: path, user: "alice"}, http.StatusOK)
+}
@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"blogmanager/middleware"
+	"blogmanager/service"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxArchiveSize bounds the archives accepted by ImportArchive.
+const maxArchiveSize = 64 << 20
+
+// ArchiveController lets admins export and import all blogs.
+type ArchiveController struct {
+	ArchiveService *service.ArchiveService
+}
+
+func NewArchiveController(archiveService *service.ArchiveService) *ArchiveController {
+	return &ArchiveController{ArchiveService: archiveService}
+}
+
+// ExportArchive downloads every blog as a zip archive.
+func (controller *ArchiveController) ExportArchive(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	// Build the archive first so that a failure can still be reported
+	var archive bytes.Buffer
+	if err := controller.ArchiveService.ExportArchive(&archive); err != nil {
+		respondWithServiceError(c, err)
+		return
+	}
+	filename := fmt.Sprintf("blogs-%s.zip", time.Now().UTC().Format("20060102-150405"))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, "application/zip", archive.Bytes())
+}
+
+// ImportArchive imports the zip archive sent as the request body. The
+// conflict query parameter picks the conflict strategy, skip by default.
+func (controller *ArchiveController) ImportArchive(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxArchiveSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("archives must be at most %d bytes", maxArchiveSize)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	conflict := c.DefaultQuery("conflict", service.ConflictSkip)
+	report, err := controller.ArchiveService.ImportArchive(bytes.NewReader(data), int64(len(data)), conflict)
+	if errors.Is(err, service.ErrInvalidArchive) || errors.Is(err, service.ErrInvalidConflict) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		respondWithServiceError(c, err)
+		return
+	}
+
+	slog.InfoContext(c.Request.Context(), "blogs imported", "created", report.Created, "updated", report.Updated,
+		"renamed", report.Renamed, "skipped", report.Skipped, "unchanged", report.Unchanged, "errors", len(report.Errors))
+	c.JSON(http.StatusOK, report)
+}
+
+// requireAdmin writes a 401 or 403 response and returns false unless the
+// request comes from an admin.
+func requireAdmin(c *gin.Context) bool {
+	user := middleware.CurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return false
+	}
+	if !user.IsAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can export and import blogs"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,127 @@
+package controller
+
+import (
+	"blogmanager/middleware"
+	"blogmanager/service"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AttachmentController serves blog attachments. Uploads larger than
+// MaxUploadSize bytes are refused with 413.
+type AttachmentController struct {
+	AttachmentService *service.AttachmentService
+	MaxUploadSize     int64
+}
+
+func NewAttachmentController(attachmentService *service.AttachmentService, maxUploadSize int64) *AttachmentController {
+	return &AttachmentController{AttachmentService: attachmentService, MaxUploadSize: maxUploadSize}
+}
+
+// UploadAttachment stores the file sent in the "file" field of a multipart
+// form as an attachment of a blog.
+func (controller *AttachmentController) UploadAttachment(c *gin.Context) {
+	blogID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	user := middleware.CurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// Leave room for the multipart framing around the file
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, controller.MaxUploadSize+1<<20)
+	header, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			controller.respondTooLarge(c)
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request must be a multipart form with a file field"})
+		return
+	}
+	if header.Size > controller.MaxUploadSize {
+		controller.respondTooLarge(c)
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	attachment, err := controller.AttachmentService.Upload(blogID, user, header.Filename, file)
+	switch {
+	case errors.Is(err, service.ErrUnsupportedMediaType):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, service.ErrInvalidAttachmentName):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		respondWithServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, attachment)
+}
+
+func (controller *AttachmentController) GetAttachments(c *gin.Context) {
+	blogID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	attachments, err := controller.AttachmentService.GetAttachments(blogID, middleware.CurrentUser(c))
+	if err != nil {
+		respondWithServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, attachments)
+}
+
+// ServeMedia serves an attached file. Files are named by the hash of their
+// content, so they never change and can be cached for good, by shared caches
+// only when anyone may see them.
+func (controller *AttachmentController) ServeMedia(c *gin.Context) {
+	hash := c.Param("hash")
+	viewer := middleware.CurrentUser(c)
+	file, contentType, err := controller.AttachmentService.OpenMedia(hash, viewer)
+	if errors.Is(err, service.ErrMediaNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Media not found"})
+		return
+	}
+	if err != nil {
+		respondWithServiceError(c, err)
+		return
+	}
+	defer file.Close()
+
+	c.Header("Content-Type", contentType)
+	if viewer == nil {
+		c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		c.Header("Cache-Control", "private, max-age=31536000, immutable")
+	}
+	c.Header("ETag", `"`+hash+`"`)
+	c.Header("X-Content-Type-Options", "nosniff")
+	http.ServeContent(c.Writer, c.Request, "", time.Time{}, file)
+}
+
+func (controller *AttachmentController) respondTooLarge(c *gin.Context) {
+	c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+		"error": fmt.Sprintf("attachments must be at most %d bytes", controller.MaxUploadSize),
+	})
+}
@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+var testPNG = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01")
+
+// uploadPNG attaches content, which must be sniffed as a PNG, to the blog
+// at path and returns the URL it is served at.
+func (api *testAPI) uploadPNG(user, path string, content []byte) string {
+	api.t.Helper()
+	w := api.upload(user, path, "picture.png", content)
+	if w.Code != http.StatusCreated {
+		api.t.Fatalf("upload: status %d, want %d: %s", w.Code, http.StatusCreated, w.Body)
+	}
+	var attachment struct {
+		URL string `json:"url"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &attachment); err != nil {
+		api.t.Fatal(err)
+	}
+	return attachment.URL
+}
+
+func TestUploadAttachment(t *testing.T) {
+	api := newTestAPI(t, false)
+	api.register("alice")
+	path := api.createBlog("alice", "Pictures")
+
+	tests := []struct {
+		name, filename string
+		content        []byte
+		status         int
+	}{
+		{"png", "cat.png", testPNG, http.StatusCreated},
+		{"html disguised as png", "cat.png", []byte("<html><script>alert(1)</script></html>"), http.StatusUnsupportedMediaType},
+		{"over the limit", "big.png", append(testPNG, make([]byte, testMaxUploadSize)...), http.StatusRequestEntityTooLarge},
+		// Cut off by the request body limit before the form is parsed
+		{"far over the limit", "huge.png", append(testPNG, make([]byte, 2<<20)...), http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := api.upload("alice", path, tt.filename, tt.content); w.Code != tt.status {
+				t.Errorf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+
+	w := api.upload("alice", path, `C:\Users\alice\`+strings.Repeat("x", 300)+".png", testPNG)
+	var attachment struct {
+		Filename string `json:"filename"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &attachment); err != nil || attachment.Filename != strings.Repeat("x", 251)+".png" {
+		t.Errorf("filename of an upload sent with a long path = %q, %v", attachment.Filename, err)
+	}
+}
+
+func TestServeMedia(t *testing.T) {
+	api := newTestAPI(t, false)
+	api.register("alice")
+	api.register("bob")
+	path := api.createBlog("alice", "Pictures")
+	url := api.uploadPNG("alice", path, testPNG)
+
+	w := api.expect(request{method: http.MethodGet, path: url}, http.StatusOK)
+	hash := strings.TrimPrefix(url, "/media/")
+	headers := map[string]string{
+		"Content-Type":           "image/png",
+		"Cache-Control":          "public, max-age=31536000, immutable",
+		"ETag":                   `"` + hash + `"`,
+		"X-Content-Type-Options": "nosniff",
+	}
+	for name, want := range headers {
+		if got := w.Header().Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if !bytes.Equal(w.Body.Bytes(), testPNG) {
+		t.Errorf("body = %q, want the upload", w.Body)
+	}
+	api.expect(request{method: http.MethodGet, path: url, headers: []string{"If-None-Match", `"` + hash + `"`}}, http.StatusNotModified)
+	api.expect(request{method: http.MethodGet, path: "/media/" + strings.Repeat("0", 64)}, http.StatusNotFound)
+	api.expect(request{method: http.MethodGet, path: "/media/not-a-hash"}, http.StatusNotFound)
+
+	// Files of drafts are only served to those who can see the draft, and
+	// never to shared caches
+	api.expect(request{method: http.MethodPut, path: path, user: "alice",
+		body: `{"title":"Pictures","content":"Content","status":"draft"}`}, http.StatusOK)
+	api.expect(request{method: http.MethodGet, path: url}, http.StatusNotFound)
+	api.expect(request{method: http.MethodGet, path: url, user: "bob"}, http.StatusNotFound)
+	w = api.expect(request{method: http.MethodGet, path: url, user: "alice"}, http.StatusOK)
+	if got := w.Header().Get("Cache-Control"); !strings.HasPrefix(got, "private") {
+		t.Errorf("Cache-Control of a draft's file = %q, want private", got)
+	}
+
+	// Nor are files of trashed blogs, even to their author
+	api.expect(request{method: http.MethodDelete, path: path, user: "alice"}, http.StatusOK)
+	api.expect(request{method: http.MethodGet, path: url, user: "alice"}, http.StatusNotFound)
+	api.expect(request{method: http.MethodPost, path: path + "/restore", user: "alice"}, http.StatusOK)
+	api.expect(request{method: http.MethodGet, path: url, user: "alice"}, http.StatusOK)
+}
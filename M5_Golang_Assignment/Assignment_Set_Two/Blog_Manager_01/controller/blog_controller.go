@@ -1,42 +1,53 @@
 package controller
 
 import (
+	"blogmanager/metrics"
+	"blogmanager/middleware"
 	"blogmanager/model"
+	"blogmanager/repository"
 	"blogmanager/service"
-	"fmt"
+	"errors"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// BlogController serves the blog API. With RequireIfMatch set, updates,
+// patches and deletes without an If-Match header are refused with 428.
 type BlogController struct {
-	BlogService *service.BlogService
+	BlogService    *service.BlogService
+	RequireIfMatch bool
 }
 
-func NewBlogController(blogService *service.BlogService) *BlogController {
-	return &BlogController{BlogService: blogService}
+func NewBlogController(blogService *service.BlogService, requireIfMatch bool) *BlogController {
+	return &BlogController{BlogService: blogService, RequireIfMatch: requireIfMatch}
 }
 func (controller *BlogController) CreateBlog(c *gin.Context) {
-	fmt.Println("CreateBlog: Received request to create a Blog")
-
 	var blog model.Blog
 	if err := c.ShouldBindJSON(&blog); err != nil {
-		fmt.Println("CreateBlog: Invalid JSON:", err)
+		slog.DebugContext(c.Request.Context(), "invalid blog JSON", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 		return
 	}
 
-	fmt.Printf("CreateBlog: Parsed Blog details: %+v\n", blog)
-
-	createdBlog, err := controller.BlogService.CreateBlog(&blog)
+	createdBlog, err := controller.BlogService.CreateBlog(&blog, middleware.CurrentUser(c))
+	if isInvalidBlog(err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
-		fmt.Printf("CreateBlog: Error creating Blog in service layer: %v\n", err)
+		slog.ErrorContext(c.Request.Context(), "failed to create blog", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create Blog"})
 		return
 	}
 
-	fmt.Printf("CreateBlog: Successfully created Blog: %+v\n", createdBlog)
+	metrics.BlogsCreated.Inc()
+	slog.InfoContext(c.Request.Context(), "blog created", "blog_id", createdBlog.ID, "status", createdBlog.Status)
+	c.Header("ETag", blogETag(createdBlog))
 	c.JSON(http.StatusOK, createdBlog)
 }
 
@@ -48,23 +59,96 @@ func (controller *BlogController) GetBlog(c *gin.Context) {
 		return
 	}
 
-	Blog, err := controller.BlogService.GetBlog(BlogID)
+	Blog, err := controller.BlogService.GetBlog(BlogID, middleware.CurrentUser(c))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Blog not found"})
+		respondWithServiceError(c, err)
 		return
 	}
 
+	switch c.Query("render") {
+	case "":
+	case "html":
+		if err := controller.BlogService.RenderBlog(Blog); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "render must be html"})
+		return
+	}
+
+	c.Header("ETag", blogETag(Blog))
 	c.JSON(http.StatusOK, Blog)
 }
 
 func (controller *BlogController) GetAllBlogs(c *gin.Context) {
-	Blogs, err := controller.BlogService.GetAllBlogs()
+	opts := model.BlogListOptions{
+		After:  c.Query("after"),
+		Sort:   c.Query("sort"),
+		Author: c.Query("author"),
+		Tag:    c.Query("tag"),
+		Status: c.Query("status"),
+		Viewer: middleware.CurrentUser(c),
+	}
+
+	var err error
+	if opts.Limit, err = strconv.Atoi(c.DefaultQuery("limit", "0")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+	if opts.From, err = parseDateParam(c.Query("from"), false); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date, use YYYY-MM-DD or RFC 3339"})
+		return
+	}
+	if opts.To, err = parseDateParam(c.Query("to"), true); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date, use YYYY-MM-DD or RFC 3339"})
+		return
+	}
+
+	page, err := controller.BlogService.GetAllBlogs(opts)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidSort), errors.Is(err, service.ErrInvalidRange),
+			errors.Is(err, service.ErrInvalidStatus), errors.Is(err, repository.ErrInvalidCursor):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+func (controller *BlogController) SearchBlogs(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+
+	results, err := controller.BlogService.SearchBlogs(c.Query("q"), limit, middleware.CurrentUser(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrEmptyQuery):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+func (controller *BlogController) GetTags(c *gin.Context) {
+	tags, err := controller.BlogService.GetTags()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, Blogs)
+	c.JSON(http.StatusOK, tags)
 }
 
 func (controller *BlogController) UpdateBlog(c *gin.Context) {
@@ -81,16 +165,72 @@ func (controller *BlogController) UpdateBlog(c *gin.Context) {
 		return
 	}
 
+	// The version to update comes from If-Match, never from the body
+	version, ok := controller.expectedVersion(c, BlogID)
+	if !ok {
+		return
+	}
+
 	Blog.ID = BlogID
-	updatedBlog, err := controller.BlogService.UpdateBlog(&Blog)
+	Blog.Version = version
+	updatedBlog, err := controller.BlogService.UpdateBlog(&Blog, middleware.CurrentUser(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondWithServiceError(c, err)
 		return
 	}
 
+	slog.InfoContext(c.Request.Context(), "blog updated", "blog_id", updatedBlog.ID)
+	c.Header("ETag", blogETag(updatedBlog))
 	c.JSON(http.StatusOK, updatedBlog)
 }
 
+// PatchBlog applies a JSON merge patch or JSON patch, chosen by the
+// Content-Type, to a blog.
+func (controller *BlogController) PatchBlog(c *gin.Context) {
+	BlogID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	mediaType := c.ContentType()
+	if mediaType != service.MergePatch && mediaType != service.JSONPatch {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": service.ErrUnsupportedPatch.Error()})
+		return
+	}
+	patch, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	version, ok := controller.expectedVersion(c, BlogID)
+	if !ok {
+		return
+	}
+
+	patchedBlog, err := controller.BlogService.PatchBlog(BlogID, version, mediaType, patch, middleware.CurrentUser(c))
+	switch {
+	case errors.Is(err, service.ErrInvalidPatch):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, service.ErrPatchTestFailed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case isInvalidBlog(err):
+		// The patch was well-formed but the blog it makes is not
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		respondWithServiceError(c, err)
+		return
+	}
+
+	slog.InfoContext(c.Request.Context(), "blog patched", "blog_id", patchedBlog.ID)
+	c.Header("ETag", blogETag(patchedBlog))
+	c.JSON(http.StatusOK, patchedBlog)
+}
+
 func (controller *BlogController) DeleteBlog(c *gin.Context) {
 	id := c.Param("id")
 	BlogID, err := strconv.Atoi(id)
@@ -99,11 +239,198 @@ func (controller *BlogController) DeleteBlog(c *gin.Context) {
 		return
 	}
 
-	err = controller.BlogService.DeleteBlog(BlogID)
+	version, ok := controller.expectedVersion(c, BlogID)
+	if !ok {
+		return
+	}
+
+	err = controller.BlogService.DeleteBlog(BlogID, version, middleware.CurrentUser(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondWithServiceError(c, err)
+		return
+	}
+
+	slog.InfoContext(c.Request.Context(), "blog moved to trash", "blog_id", BlogID)
+	c.JSON(http.StatusOK, gin.H{"message": "Blog moved to trash"})
+}
+
+// GetTrash lists the trashed blogs of the user, or all of them for admins.
+func (controller *BlogController) GetTrash(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	blogs, err := controller.BlogService.GetTrash(user)
+	if err != nil {
+		respondWithServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, blogs)
+}
+
+func (controller *BlogController) RestoreBlog(c *gin.Context) {
+	BlogID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	restoredBlog, err := controller.BlogService.RestoreBlog(BlogID, middleware.CurrentUser(c))
+	if err != nil {
+		respondWithServiceError(c, err)
+		return
+	}
+
+	slog.InfoContext(c.Request.Context(), "blog restored from trash", "blog_id", BlogID)
+	c.Header("ETag", blogETag(restoredBlog))
+	c.JSON(http.StatusOK, restoredBlog)
+}
+
+func (controller *BlogController) GetRevisions(c *gin.Context) {
+	BlogID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	revisions, err := controller.BlogService.GetRevisions(BlogID, middleware.CurrentUser(c))
+	if err != nil {
+		respondWithServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, revisions)
+}
+
+func (controller *BlogController) GetRevisionDiff(c *gin.Context) {
+	BlogID, revision, ok := parseRevisionParams(c)
+	if !ok {
+		return
+	}
+
+	diff, err := controller.BlogService.DiffRevision(BlogID, revision, middleware.CurrentUser(c))
+	if err != nil {
+		respondWithServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, diff)
+}
+
+func (controller *BlogController) RestoreRevision(c *gin.Context) {
+	BlogID, revision, ok := parseRevisionParams(c)
+	if !ok {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Blog deleted successfully"})
+	restoredBlog, err := controller.BlogService.RestoreRevision(BlogID, revision, middleware.CurrentUser(c))
+	if err != nil {
+		respondWithServiceError(c, err)
+		return
+	}
+
+	c.Header("ETag", blogETag(restoredBlog))
+	c.JSON(http.StatusOK, restoredBlog)
+}
+
+// parseRevisionParams reads the :id and :rev path parameters, responding
+// with 400 when either is not a number.
+func parseRevisionParams(c *gin.Context) (int, int, bool) {
+	blogID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return 0, 0, false
+	}
+	revision, err := strconv.Atoi(c.Param("rev"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid revision"})
+		return 0, 0, false
+	}
+	return blogID, revision, true
+}
+
+// respondWithServiceError maps BlogService errors to HTTP status codes.
+func respondWithServiceError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrBlogNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Blog not found"})
+	case errors.Is(err, service.ErrRevisionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+	case errors.Is(err, service.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrVersionConflict):
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+	case isInvalidBlog(err):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		slog.ErrorContext(c.Request.Context(), "request failed", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// isInvalidBlog reports whether err says a blog failed validation.
+func isInvalidBlog(err error) bool {
+	return errors.Is(err, service.ErrInvalidBlog) || errors.Is(err, service.ErrInvalidTags) ||
+		errors.Is(err, service.ErrInvalidStatus) || errors.Is(err, service.ErrInvalidSchedule) ||
+		errors.Is(err, service.ErrInvalidFormat)
+}
+
+// blogETag is the entity tag of the current version of a blog.
+func blogETag(blog *model.Blog) string {
+	return `"` + strconv.Itoa(blog.Version) + `"`
+}
+
+// expectedVersion returns the version of the blog named by the If-Match
+// header of an update or delete, or 0 when any version will do. When the
+// request must not go ahead it writes the 428 or 412 response and returns
+// false.
+func (controller *BlogController) expectedVersion(c *gin.Context, id int) (int, bool) {
+	ifMatch := strings.TrimSpace(c.GetHeader("If-Match"))
+	switch ifMatch {
+	case "":
+		if controller.RequireIfMatch {
+			c.JSON(http.StatusPreconditionRequired, gin.H{"error": "If-Match header with the blog's ETag is required"})
+			return 0, false
+		}
+		return 0, true
+	case "*":
+		return 0, true
+	}
+
+	current, err := controller.BlogService.GetBlog(id, middleware.CurrentUser(c))
+	if err != nil {
+		respondWithServiceError(c, err)
+		return 0, false
+	}
+	etag := blogETag(current)
+	for _, tag := range strings.Split(ifMatch, ",") {
+		if strings.TrimSpace(tag) == etag {
+			return current.Version, true
+		}
+	}
+	c.Header("ETag", etag)
+	c.JSON(http.StatusPreconditionFailed, gin.H{"error": repository.ErrVersionConflict.Error()})
+	return 0, false
+}
+
+// parseDateParam accepts an RFC 3339 timestamp or a plain YYYY-MM-DD date.
+// A plain date used as an upper bound covers the whole day.
+func parseDateParam(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Second)
+	}
+	return t, nil
 }
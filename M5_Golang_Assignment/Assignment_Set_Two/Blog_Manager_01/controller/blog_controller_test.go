@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"blogmanager/middleware"
+	"blogmanager/model"
+	"blogmanager/repository"
+	"blogmanager/service"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestRouter serves the blog routes as alice, without authentication.
+func newTestRouter(blogService *service.BlogService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	blogController := NewBlogController(blogService, false)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set(middleware.UserKey, &model.User{ID: 1, Username: "alice"})
+	})
+	r.POST("/api/blog", blogController.CreateBlog)
+	r.PUT("/api/blog/:id", blogController.UpdateBlog)
+	r.PATCH("/api/blog/:id", blogController.PatchBlog)
+	return r
+}
+
+func TestBlogValidationResponses(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        string
+		wantStatus  int
+		wantETag    string
+	}{
+		{"create", http.MethodPost, "/api/blog", "application/json", `{"title":"T","content":"C"}`, http.StatusOK, `"1"`},
+		{"create empty", http.MethodPost, "/api/blog", "application/json", `{}`, http.StatusBadRequest, ""},
+		{"create without content", http.MethodPost, "/api/blog", "application/json", `{"title":"T"}`, http.StatusBadRequest, ""},
+		{"update empty", http.MethodPut, "/api/blog/1", "application/json", `{"title":"","content":""}`, http.StatusBadRequest, ""},
+		{"patch", http.MethodPatch, "/api/blog/1", service.MergePatch, `{"title":"New"}`, http.StatusOK, `"2"`},
+		{"patch to empty", http.MethodPatch, "/api/blog/1", service.MergePatch, `{"title":null,"content":""}`, http.StatusUnprocessableEntity, ""},
+		{"patch invalid status", http.MethodPatch, "/api/blog/1", service.MergePatch, `{"status":"hidden"}`, http.StatusUnprocessableEntity, ""},
+		{"patch no-op", http.MethodPatch, "/api/blog/1", service.MergePatch, `{"status":null}`, http.StatusOK, `"1"`},
+		{"patch malformed", http.MethodPatch, "/api/blog/1", service.MergePatch, `{"author":"x"}`, http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blogService := service.NewBlogService(repository.NewMemoryBlogStore())
+			_, err := blogService.CreateBlog(&model.Blog{Title: "Title", Content: "Content"}, &model.User{ID: 1, Username: "alice"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			newTestRouter(blogService).ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if got := w.Header().Get("ETag"); got != tt.wantETag {
+				t.Errorf("ETag = %s, want %s", got, tt.wantETag)
+			}
+		})
+	}
+}
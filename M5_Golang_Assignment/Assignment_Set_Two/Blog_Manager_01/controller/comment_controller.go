@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"blogmanager/middleware"
+	"blogmanager/model"
+	"blogmanager/service"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CommentController struct {
+	CommentService *service.CommentService
+}
+
+func NewCommentController(commentService *service.CommentService) *CommentController {
+	return &CommentController{CommentService: commentService}
+}
+
+func (controller *CommentController) GetComments(c *gin.Context) {
+	blogID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	comments, err := controller.CommentService.GetComments(blogID, middleware.CurrentUser(c))
+	if err != nil {
+		respondWithCommentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, comments)
+}
+
+func (controller *CommentController) CreateComment(c *gin.Context) {
+	blogID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var body struct {
+		Content  string `json:"content"`
+		ParentID *int   `json:"parent_id"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	comment := &model.Comment{BlogID: blogID, ParentID: body.ParentID, Content: body.Content}
+	createdComment, err := controller.CommentService.CreateComment(comment, middleware.CurrentUser(c))
+	if err != nil {
+		respondWithCommentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, createdComment)
+}
+
+func (controller *CommentController) UpdateComment(c *gin.Context) {
+	commentID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var body struct {
+		Content string `json:"content"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	updatedComment, err := controller.CommentService.UpdateComment(commentID, body.Content, middleware.CurrentUser(c))
+	if err != nil {
+		respondWithCommentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, updatedComment)
+}
+
+func (controller *CommentController) DeleteComment(c *gin.Context) {
+	commentID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	err = controller.CommentService.DeleteComment(commentID, middleware.CurrentUser(c))
+	if err != nil {
+		respondWithCommentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Comment deleted successfully"})
+}
+
+// respondWithCommentError maps CommentService errors to HTTP status codes.
+func respondWithCommentError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrEmptyComment), errors.Is(err, service.ErrInvalidParent):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrCommentForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrBlogNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Blog not found"})
+	case errors.Is(err, service.ErrCommentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestComments(t *testing.T) {
+	api := newTestAPI(t, false)
+	api.register("alice")
+	api.register("bob")
+	path := api.createBlog("alice", "Commented")
+
+	w := api.expect(request{method: http.MethodPost, path: path + "/comments", user: "bob", body: `{"content":"First"}`}, http.StatusCreated)
+	var comment struct {
+		ID        int       `json:"id"`
+		CreatedAt time.Time `json:"created_at"`
+		UpdatedAt time.Time `json:"updated_at"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &comment); err != nil || comment.CreatedAt.IsZero() {
+		t.Fatalf("comment %s: %v", w.Body, err)
+	}
+	if !strings.Contains(w.Body.String(), `"created_at":"`+comment.CreatedAt.Format(time.RFC3339)+`"`) {
+		t.Errorf("created_at is not RFC 3339 like the blogs': %s", w.Body)
+	}
+	commentPath := "/api/comments/" + strconv.Itoa(comment.ID)
+
+	w = api.expect(request{method: http.MethodPost, path: path + "/comments", user: "alice",
+		body: `{"content":"Reply","parent_id":` + strconv.Itoa(comment.ID) + `}`}, http.StatusCreated)
+	var reply struct {
+		ID int `json:"id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &reply); err != nil {
+		t.Fatal(err)
+	}
+	api.expect(request{method: http.MethodPost, path: path + "/comments", user: "bob",
+		body: `{"content":"Nested","parent_id":` + strconv.Itoa(reply.ID) + `}`}, http.StatusBadRequest)
+	other := api.createBlog("alice", "Other")
+	api.expect(request{method: http.MethodPost, path: other + "/comments", user: "bob",
+		body: `{"content":"Elsewhere","parent_id":` + strconv.Itoa(comment.ID) + `}`}, http.StatusBadRequest)
+
+	api.expect(request{method: http.MethodPut, path: commentPath, user: "alice", body: `{"content":"Changed"}`}, http.StatusForbidden)
+	api.expect(request{method: http.MethodDelete, path: commentPath, user: "alice"}, http.StatusForbidden)
+	api.expect(request{method: http.MethodPut, path: commentPath, user: "bob", body: `{"content":"Changed"}`}, http.StatusOK)
+
+	// Deleting a comment takes its replies with it
+	api.expect(request{method: http.MethodDelete, path: commentPath, user: "bob"}, http.StatusOK)
+	w = api.expect(request{method: http.MethodGet, path: path + "/comments", user: "bob"}, http.StatusOK)
+	if body := strings.TrimSpace(w.Body.String()); body != "[]" {
+		t.Errorf("comments after deleting the thread: %s", body)
+	}
+}
@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"blogmanager/service"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FeedController struct {
+	FeedService *service.FeedService
+}
+
+func NewFeedController(feedService *service.FeedService) *FeedController {
+	return &FeedController{FeedService: feedService}
+}
+
+func (controller *FeedController) GetRSS(c *gin.Context) {
+	feed, err := controller.FeedService.RSS(baseURL(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	writeFeed(c, feed, "application/rss+xml; charset=utf-8")
+}
+
+func (controller *FeedController) GetAtom(c *gin.Context) {
+	feed, err := controller.FeedService.Atom(baseURL(c), "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	writeFeed(c, feed, "application/atom+xml; charset=utf-8")
+}
+
+func (controller *FeedController) GetAuthorAtom(c *gin.Context) {
+	feed, err := controller.FeedService.Atom(baseURL(c), c.Param("name"))
+	if err != nil {
+		if errors.Is(err, service.ErrAuthorNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Author not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	writeFeed(c, feed, "application/atom+xml; charset=utf-8")
+}
+
+// writeFeed sends the feed with its validators, or 304 Not Modified when the
+// client's cached copy is still current. If-None-Match takes precedence over
+// If-Modified-Since as required by RFC 9110.
+func writeFeed(c *gin.Context, feed *service.Feed, contentType string) {
+	c.Header("ETag", feed.ETag)
+	c.Header("Last-Modified", feed.LastModified.Format(http.TimeFormat))
+	c.Header("Cache-Control", "public, max-age=300")
+
+	if match := c.GetHeader("If-None-Match"); match != "" {
+		if match == feed.ETag || match == "*" || match == "W/"+feed.ETag {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	} else if since, err := time.Parse(http.TimeFormat, c.GetHeader("If-Modified-Since")); err == nil {
+		if !feed.LastModified.After(since) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+
+	c.Data(http.StatusOK, contentType, feed.Body)
+}
+
+// baseURL is the scheme and host the request was addressed to, honouring
+// X-Forwarded-Proto from a reverse proxy.
+func baseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto == "https" || proto == "http" {
+		scheme = proto
+	}
+	return scheme + "://" + c.Request.Host
+}
@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"blogmanager/middleware"
+	"blogmanager/repository"
+	"blogmanager/service"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UserController struct {
+	UserService *service.UserService
+}
+
+func NewUserController(userService *service.UserService) *UserController {
+	return &UserController{UserService: userService}
+}
+
+func (controller *UserController) Register(c *gin.Context) {
+	var credentials struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&credentials); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	user, err := controller.UserService.RegisterUser(credentials.Username, credentials.Password)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidUserData), errors.Is(err, service.ErrInvalidUsername):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, repository.ErrUsernameTaken):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			slog.ErrorContext(c.Request.Context(), "failed to register user", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register user"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, user)
+}
+
+func (controller *UserController) ChangePassword(c *gin.Context) {
+	var body struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	err := controller.UserService.ChangePassword(middleware.CurrentUser(c).Username, body.CurrentPassword, body.NewPassword)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidUserData):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrInvalidCredentials):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Current password is incorrect"})
+		default:
+			slog.ErrorContext(c.Request.Context(), "failed to change password", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
+}
@@ -1,40 +1,223 @@
 package main
 
 import (
+	"blogmanager/cache"
 	db "blogmanager/config"
 	"blogmanager/controller"
+	"blogmanager/logging"
+	"blogmanager/metrics"
 	"blogmanager/middleware"
 	"blogmanager/repository"
 	"blogmanager/service"
+	"blogmanager/settings"
+	"blogmanager/storage"
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"servicekit/health"
+	"servicekit/ratelimit"
+	"servicekit/server"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
-	db.InitializeDatabase()
+	cfg, args, err := settings.Load(os.Args[1:])
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		log.Fatal(err)
+	}
+
+	// Log JSON lines to stderr, leaving stdout to subcommand output
+	var level slog.Level
+	level.UnmarshalText([]byte(cfg.LogLevel))
+	slog.SetDefault(logging.New(os.Stderr, level))
+	if level > slog.LevelDebug {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	if len(args) > 0 {
+		var err error
+		switch args[0] {
+		case "migrate":
+			err = runMigrate(cfg, args[1:])
+		case "export":
+			err = runExport(cfg, args[1:])
+		case "import":
+			err = runImport(cfg, args[1:])
+		case "create-admin":
+			err = runCreateAdmin(cfg, args[1:])
+		default:
+			log.Fatalf("unknown command %q", args[0])
+		}
+		if err != nil && !errors.Is(err, flag.ErrHelp) {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if err := db.InitializeDatabase(cfg.Database.Driver, cfg.DSN()); err != nil {
+		log.Fatal(err)
+	}
+	dialect := repository.Dialect(db.Driver)
+	metrics.Registry.RegisterDB(db.GetDB(), "blogs")
 
 	// Create repository, service, and controller for products
-	blogRepo := repository.NewBlogRepository(db.GetDB())
-	blogService := service.NewBlogService(blogRepo)
-	blogController := controller.NewBlogController(blogService)
+	blogRepo := repository.NewBlogRepository(db.GetDB(), dialect)
+	var blogStore repository.BlogStore = blogRepo
+	if cfg.Cache.Size > 0 {
+		// Serve repeated blog reads from memory until a write invalidates them
+		blogCache := cache.NewLRU(cfg.Cache.Size)
+		metrics.RegisterCache("blogs", blogCache.Stats)
+		blogStore = repository.NewCachedBlogStore(blogRepo, blogCache, time.Duration(cfg.Cache.TTL))
+	}
+	blogService := service.NewBlogService(blogStore)
+	blogController := controller.NewBlogController(blogService, cfg.RequireIfMatch)
+
+	userRepo := repository.NewUserRepository(db.GetDB(), dialect)
+	userService := service.NewUserService(userRepo)
+	userController := controller.NewUserController(userService)
+	if hasAdmin, err := userRepo.HasAdmin(); err == nil && !hasAdmin {
+		slog.Warn("there is no admin; create one with blogmanager create-admin")
+	}
+
+	commentRepo := repository.NewCommentRepository(db.GetDB(), dialect)
+	commentService := service.NewCommentService(commentRepo, blogStore)
+	commentController := controller.NewCommentController(commentService)
+
+	mediaStorage, err := storage.NewLocalStorage(cfg.Media.Dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	attachmentRepo := repository.NewAttachmentRepository(db.GetDB(), dialect)
+	attachmentService := service.NewAttachmentService(attachmentRepo, blogStore, mediaStorage)
+	attachmentController := controller.NewAttachmentController(attachmentService, cfg.Media.MaxUploadBytes)
+
+	// Files of purged blogs are removed along with them
+	blogService.OnPurge = attachmentService.RemoveUnreferenced
+
+	archiveService := service.NewArchiveService(blogService, userRepo)
+	archiveController := controller.NewArchiveController(archiveService)
+
+	feedService := service.NewFeedService(blogService, userRepo)
+	feedController := controller.NewFeedController(feedService)
+
+	// Publish scheduled blogs in the background once they are due
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		blogService.PublishScheduledBlogs(workerCtx, 30*time.Second)
+	}()
 
-	// Initialize Gin router
-	r := gin.Default()
+	// Purge blogs that have been in the trash for longer than the retention
+	retention := time.Duration(cfg.TrashRetention)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		blogService.PurgeTrash(workerCtx, min(retention, time.Hour), retention)
+	}()
 
-	// Apply logging middleware globally
-	r.Use(middleware.LoggingMiddleware())
+	// Readiness fails while the database is unreachable, locked or behind on
+	// migrations, or a background worker has stopped
+	checker := health.NewChecker(2 * time.Second)
+	checker.Add("database", db.Ping)
+	checker.Add("migrations", db.CheckMigrations)
+	checker.Add("publisher", func(context.Context) error { return blogService.CheckPublisher() })
+	checker.Add("purger", func(context.Context) error { return blogService.CheckPurger() })
+
+	// Limit requests per client IP before authentication and per user after it
+	routeLimits := make(map[string]ratelimit.Limit, len(cfg.RateLimit.Routes))
+	for route, limit := range cfg.RateLimit.Routes {
+		routeLimits[route] = ratelimit.Limit(limit)
+	}
+	idleTimeout := time.Duration(cfg.RateLimit.IdleTimeout)
+	ipLimiter := ratelimit.NewLimiter(ratelimit.Limit(cfg.RateLimit.IP), routeLimits, idleTimeout, ratelimit.ByIP)
+	userLimiter := ratelimit.NewLimiter(ratelimit.Limit(cfg.RateLimit.User), routeLimits, idleTimeout, middleware.RateLimitByUser)
+
+	r, err := newRouter(cfg, checker, ipLimiter)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Registration, the feeds of published blogs and the files attached to
+	// them do not require credentials; files of other blogs are served to
+	// those who can see the blog
+	r.POST("/api/register", userController.Register)
+	r.GET("/feed.rss", feedController.GetRSS)
+	r.GET("/feed.atom", feedController.GetAtom)
+	r.GET("/authors/:name/feed.atom", feedController.GetAuthorAtom)
+	r.GET("/media/:hash", middleware.AuthMiddleware(userService, true), attachmentController.ServeMedia)
 
 	// Group routes and apply authentication middleware
 	api := r.Group("/api")
-	api.Use(middleware.AuthMiddleware(db.GetDB()))
+	api.Use(middleware.AuthMiddleware(userService, cfg.AuthMode == settings.AuthPublicRead), userLimiter.Middleware())
 
 	// Routes for users
+	api.PUT("/user/password", userController.ChangePassword)
+
+	// Routes for blogs
 	api.POST("/blog", blogController.CreateBlog)
+	api.GET("/blog/search", blogController.SearchBlogs)
 	api.GET("/blog/:id", blogController.GetBlog)
 	api.GET("/blog", blogController.GetAllBlogs)
 	api.PUT("/blog/:id", blogController.UpdateBlog)
+	api.PATCH("/blog/:id", blogController.PatchBlog)
 	api.DELETE("/blog/:id", blogController.DeleteBlog)
+	api.GET("/trash", blogController.GetTrash)
+	api.POST("/blog/:id/restore", blogController.RestoreBlog)
+	api.GET("/tags", blogController.GetTags)
+
+	// Routes for blog revisions
+	api.GET("/blog/:id/revisions", blogController.GetRevisions)
+	api.GET("/blog/:id/revisions/:rev/diff", blogController.GetRevisionDiff)
+	api.POST("/blog/:id/revisions/:rev/restore", blogController.RestoreRevision)
+
+	// Routes for attachments
+	api.GET("/blog/:id/attachments", attachmentController.GetAttachments)
+	api.POST("/blog/:id/attachments", attachmentController.UploadAttachment)
+
+	// Routes for admins to move blogs between installations
+	api.GET("/admin/export", archiveController.ExportArchive)
+	api.POST("/admin/import", archiveController.ImportArchive)
+
+	// Routes for comments
+	api.GET("/blog/:id/comments", commentController.GetComments)
+	api.POST("/blog/:id/comments", commentController.CreateComment)
+	api.PUT("/comments/:id", commentController.UpdateComment)
+	api.DELETE("/comments/:id", commentController.DeleteComment)
+
+	// Start server on the configured port
+	srv := &http.Server{
+		Addr:              cfg.Addr(),
+		Handler:           r,
+		ReadHeaderTimeout: time.Duration(cfg.Server.ReadTimeout),
+		ReadTimeout:       time.Duration(cfg.Server.ReadTimeout),
+		WriteTimeout:      time.Duration(cfg.Server.WriteTimeout),
+		IdleTimeout:       time.Duration(cfg.Server.IdleTimeout),
+	}
+	serveErr := server.Serve(srv, time.Duration(cfg.Server.ShutdownTimeout))
+	if serveErr != nil {
+		slog.Error("server failed", "error", serveErr)
+	}
 
-	// Start server on port 8080
-	r.Run(":8080")
+	// Stop background work before the database goes away
+	stopWorkers()
+	workers.Wait()
+	if err := db.DB.Close(); err != nil {
+		slog.Error("failed to close database", "error", err)
+	}
+	if serveErr != nil {
+		os.Exit(1)
+	}
+	slog.Info("server stopped")
 }
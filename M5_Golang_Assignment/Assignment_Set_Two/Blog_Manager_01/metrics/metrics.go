@@ -0,0 +1,55 @@
+// Package metrics holds the Prometheus metrics of the blog manager, on top of
+// the request and runtime metrics every service exports.
+package metrics
+
+import (
+	"blogmanager/cache"
+	"servicekit/httpmetrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// BlogsCreated counts the blogs created through the API.
+	BlogsCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "blogmanager_blogs_created_total",
+		Help: "Blogs created.",
+	})
+
+	// LoginsFailed counts requests rejected by authentication.
+	LoginsFailed = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "blogmanager_logins_failed_total",
+		Help: "Requests rejected because of missing or invalid credentials.",
+	})
+
+	// Registry holds every metric, served on /metrics.
+	Registry = httpmetrics.NewRegistry(BlogsCreated, LoginsFailed)
+)
+
+// RegisterCache exports the hit, miss and eviction counts and the size of a
+// cache, read from stats at every scrape.
+func RegisterCache(name string, stats func() cache.Stats) {
+	labels := prometheus.Labels{"cache": name}
+	Registry.MustRegister(
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name:        "blogmanager_cache_hits_total",
+			Help:        "Cache lookups that found a value.",
+			ConstLabels: labels,
+		}, func() float64 { return float64(stats().Hits) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name:        "blogmanager_cache_misses_total",
+			Help:        "Cache lookups that found nothing or an expired value.",
+			ConstLabels: labels,
+		}, func() float64 { return float64(stats().Misses) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name:        "blogmanager_cache_evictions_total",
+			Help:        "Cache entries dropped to make room for new ones.",
+			ConstLabels: labels,
+		}, func() float64 { return float64(stats().Evictions) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name:        "blogmanager_cache_entries",
+			Help:        "Entries currently in the cache.",
+			ConstLabels: labels,
+		}, func() float64 { return float64(stats().Entries) }),
+	)
+}
@@ -1,19 +1,42 @@
 package middleware
 
 import (
-	"database/sql"
+	"blogmanager/metrics"
+	"blogmanager/model"
+	"blogmanager/service"
 	"encoding/base64"
-	"fmt"
+	"log/slog"
+	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
-func AuthMiddleware(db *sql.DB) gin.HandlerFunc {
+// UserKey is the gin context key under which AuthMiddleware stores the
+// authenticated *model.User.
+const UserKey = "user"
+
+// CurrentUser returns the user authenticated by AuthMiddleware, or nil on
+// routes that are not behind it.
+func CurrentUser(c *gin.Context) *model.User {
+	user, _ := c.Get(UserKey)
+	u, _ := user.(*model.User)
+	return u
+}
+
+// AuthMiddleware authenticates requests with HTTP Basic credentials. With
+// anonymousReads set, GET requests without an Authorization header go through
+// without a user.
+func AuthMiddleware(userService *service.UserService, anonymousReads bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" && anonymousReads && c.Request.Method == http.MethodGet {
+			c.Next()
+			return
+		}
 		if authHeader == "" || !strings.HasPrefix(authHeader, "Basic ") {
-			fmt.Println("Missing or invalid Authorization header")
+			slog.WarnContext(c.Request.Context(), "authentication failed", "reason", "missing or non-Basic Authorization header")
+			metrics.LoginsFailed.Inc()
 			c.JSON(401, gin.H{"error": "Unauthorized"})
 			c.Abort()
 			return
@@ -22,7 +45,8 @@ func AuthMiddleware(db *sql.DB) gin.HandlerFunc {
 		// Decode the Base64-encoded credentials
 		payload, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(authHeader, "Basic "))
 		if err != nil {
-			fmt.Println("Failed to decode Authorization header:", err)
+			slog.WarnContext(c.Request.Context(), "authentication failed", "reason", "Authorization header is not valid base64")
+			metrics.LoginsFailed.Inc()
 			c.JSON(401, gin.H{"error": "Invalid Authorization Header"})
 			c.Abort()
 			return
@@ -31,7 +55,9 @@ func AuthMiddleware(db *sql.DB) gin.HandlerFunc {
 		// Split the username and password
 		credentials := strings.SplitN(string(payload), ":", 2)
 		if len(credentials) != 2 {
-			fmt.Println("Invalid credentials format:", string(payload))
+			// Never log the payload, it holds the password
+			slog.WarnContext(c.Request.Context(), "authentication failed", "reason", "credentials are not username:password")
+			metrics.LoginsFailed.Inc()
 			c.JSON(401, gin.H{"error": "Invalid Credentials"})
 			c.Abort()
 			return
@@ -39,25 +65,18 @@ func AuthMiddleware(db *sql.DB) gin.HandlerFunc {
 
 		username, password := credentials[0], credentials[1]
 
-		// Validate credentials against the database
-		var storedPassword string
-		query := "SELECT password FROM users WHERE username = ?"
-		err = db.QueryRow(query, username).Scan(&storedPassword)
+		// Validate credentials against the stored password hash
+		user, err := userService.Authenticate(username, password)
 		if err != nil {
-			fmt.Println("User not found or error querying database:", err)
-			c.JSON(401, gin.H{"error": "Unauthorized"})
-			c.Abort()
-			return
-		}
-
-		if storedPassword != password {
-			fmt.Println("Password mismatch")
+			slog.WarnContext(c.Request.Context(), "authentication failed", "reason", err.Error(), "user", username)
+			metrics.LoginsFailed.Inc()
 			c.JSON(401, gin.H{"error": "Unauthorized"})
 			c.Abort()
 			return
 		}
 
-		fmt.Println("Authentication successful")
+		slog.DebugContext(c.Request.Context(), "authenticated", "user", user.Username)
+		c.Set(UserKey, user)
 		c.Next()
 	}
 }
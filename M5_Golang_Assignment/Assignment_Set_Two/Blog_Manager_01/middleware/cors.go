@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORSMiddleware lets browsers on the given origins call the API. An origin
+// of * allows any origin. Preflight requests are answered directly.
+func CORSMiddleware(origins []string) gin.HandlerFunc {
+	allowAny := slices.Contains(origins, "*")
+	allowed := make([]string, len(origins))
+	for i, origin := range origins {
+		allowed[i] = strings.TrimSuffix(origin, "/")
+	}
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" || (!allowAny && !slices.Contains(allowed, strings.TrimSuffix(origin, "/"))) {
+			c.Next()
+			return
+		}
+
+		header := c.Writer.Header()
+		header.Add("Vary", "Origin")
+		header.Set("Access-Control-Allow-Origin", origin)
+		header.Set("Access-Control-Expose-Headers", "ETag, Last-Modified, X-Request-ID")
+
+		if c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
+			header.Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE")
+			header.Set("Access-Control-Allow-Headers", "Authorization, Content-Type, If-Match, If-None-Match, X-Request-ID")
+			header.Set("Access-Control-Max-Age", "600")
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
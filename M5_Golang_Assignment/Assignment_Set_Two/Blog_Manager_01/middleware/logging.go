@@ -1,21 +1,62 @@
 package middleware
 
 import (
-	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// LoggingMiddleware logs one line per request with its status, latency,
+// response size and authenticated user. Server errors are logged at error
+// level and client errors at warn level.
 func LoggingMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
-		fmt.Printf("Started %s %s\n", c.Request.Method, c.Request.URL.Path)
 
 		// Process the request
 		c.Next()
 
-		// Log the response time
-		fmt.Printf("Completed %s in %v\n", c.Request.URL.Path, time.Since(start))
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.Int("bytes", max(c.Writer.Size(), 0)),
+			slog.String("client_ip", c.ClientIP()),
+		}
+		if user := CurrentUser(c); user != nil {
+			attrs = append(attrs, slog.String("user", user.Username))
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("errors", c.Errors.String()))
+		}
+		slog.LogAttrs(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// RecoveryMiddleware turns a panic in a handler into a 500 response and logs
+// it with its stack trace.
+func RecoveryMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if err := recover(); err != nil {
+				slog.ErrorContext(c.Request.Context(), "panic while handling request",
+					"error", err, "stack", string(debug.Stack()))
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			}
+		}()
+		c.Next()
 	}
 }
@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitByUser keys requests by the user AuthMiddleware authenticated,
+// leaving anonymous requests unlimited.
+func RateLimitByUser(c *gin.Context) string {
+	if user := CurrentUser(c); user != nil {
+		return "user:" + strconv.Itoa(user.ID)
+	}
+	return ""
+}
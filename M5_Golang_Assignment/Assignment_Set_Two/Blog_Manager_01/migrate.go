@@ -0,0 +1,24 @@
+package main
+
+import (
+	db "blogmanager/config"
+	"blogmanager/settings"
+	"errors"
+)
+
+const migrateUsage = "usage: blogmanager [flags] migrate status|up|down [steps]"
+
+// runMigrate implements the migrate subcommand: status lists the migrations,
+// up applies the pending ones and down reverts the last steps applied ones,
+// one by default.
+func runMigrate(cfg *settings.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	if err := db.OpenDatabase(cfg.Database.Driver, cfg.DSN()); err != nil {
+		return err
+	}
+	defer db.DB.Close()
+
+	return db.Migrator().Command(args, migrateUsage)
+}
@@ -0,0 +1,14 @@
+package model
+
+// Attachment is a file uploaded to a blog. It is served at URL, which is
+// derived from the SHA-256 Hash of its content.
+type Attachment struct {
+	ID          int    `json:"id"`
+	BlogID      int    `json:"blog_id"`
+	Hash        string `json:"hash"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	URL         string `json:"url"`
+	CreatedAt   string `json:"created_at"`
+}
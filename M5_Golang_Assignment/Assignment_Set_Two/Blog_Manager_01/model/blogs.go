@@ -1,9 +1,128 @@
 package model
 
+import "time"
+
+// Blog statuses. Only published blogs are visible to users other than the
+// author; scheduled blogs are published automatically once PublishAt passes.
+const (
+	StatusDraft     = "draft"
+	StatusScheduled = "scheduled"
+	StatusPublished = "published"
+	StatusArchived  = "archived"
+)
+
+// Content formats. Blogs are rendered to sanitized HTML according to their
+// format.
+const (
+	FormatMarkdown = "markdown"
+	FormatPlain    = "plain"
+	FormatHTML     = "html"
+)
+
+// Blog is a blog post. HTML holds the rendered content and is only filled in
+// when it was asked for. Version starts at 1 and goes up with every change.
+// DeletedAt is only set on blogs in the trash.
 type Blog struct {
-	ID        int    `json:"id"`
-	Title     string `json:"title"`
-	Content   string `json:"content"`
-	Author    string `json:"author"`
-	TimeStamp string `json:"timestamp"`
+	ID        int        `json:"id"`
+	Title     string     `json:"title"`
+	Content   string     `json:"content"`
+	Author    string     `json:"author"`
+	AuthorID  int        `json:"author_id,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Version   int        `json:"version"`
+	Tags      []string   `json:"tags"`
+	Status    string     `json:"status"`
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+	Format    string     `json:"format"`
+	HTML      string     `json:"html,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// BlogRevision is a past version of a blog, saved when the blog was updated.
+// Revision numbers start at 1 for every blog. Revisions saved before status
+// and tags were recorded have an empty Status and nil Tags.
+type BlogRevision struct {
+	BlogID    int      `json:"blog_id"`
+	Revision  int      `json:"revision"`
+	Title     string   `json:"title"`
+	Content   string   `json:"content"`
+	Status    string   `json:"status,omitempty"`
+	Tags      []string `json:"tags"`
+	CreatedAt string   `json:"created_at"`
+}
+
+// RevisionDiff lists what changed from a revision to the current version of
+// a blog. Title, Status and Tags are only set when they changed, and Status
+// and Tags only when the revision recorded them. Diff is a unified diff of
+// the content alone, empty when it did not change.
+type RevisionDiff struct {
+	BlogID   int          `json:"blog_id"`
+	Revision int          `json:"revision"`
+	Title    *FieldChange `json:"title,omitempty"`
+	Status   *FieldChange `json:"status,omitempty"`
+	Tags     *TagsChange  `json:"tags,omitempty"`
+	Diff     string       `json:"diff"`
+}
+
+type FieldChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type TagsChange struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
+
+// BlogSearchResult is a blog matched by a full-text search. TitleHighlight and
+// Snippet are HTML: escaped text with the matched terms wrapped in
+// <mark></mark>. Rank is lower for better matches.
+type BlogSearchResult struct {
+	ID             int       `json:"id"`
+	Title          string    `json:"title"`
+	Author         string    `json:"author"`
+	AuthorID       int       `json:"author_id,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	TitleHighlight string    `json:"title_highlight"`
+	Snippet        string    `json:"snippet"`
+	Rank           float64   `json:"rank"`
+}
+
+// Sort orders accepted when listing blogs.
+const (
+	SortNewest = "newest"
+	SortOldest = "oldest"
+	SortTitle  = "title"
+)
+
+// BlogListOptions filters and paginates a blog listing. After is the opaque
+// cursor returned as NextCursor by the previous page; From and To bound the
+// blog creation time inclusively and are ignored when zero. Unpublished blogs are
+// only listed for their author, or for any admin Viewer.
+type BlogListOptions struct {
+	Limit  int
+	After  string
+	Sort   string
+	Author string
+	Tag    string
+	Status string
+	From   time.Time
+	To     time.Time
+	Viewer *User
+}
+
+// BlogPage is one page of a blog listing. Total counts every blog matching
+// the filters, not just the ones on this page.
+type BlogPage struct {
+	Data       []Blog `json:"data"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      int    `json:"total"`
+}
+
+// Tag is a tag together with the number of blogs carrying it.
+type Tag struct {
+	Name      string `json:"name"`
+	PostCount int    `json:"post_count"`
 }
@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// Comment is a comment on a blog. Top-level comments have no ParentID and
+// carry their direct replies; replies cannot be replied to.
+type Comment struct {
+	ID        int       `json:"id"`
+	BlogID    int       `json:"blog_id"`
+	ParentID  *int      `json:"parent_id,omitempty"`
+	Author    string    `json:"author"`
+	AuthorID  int       `json:"author_id"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Replies   []Comment `json:"replies,omitempty"`
+}
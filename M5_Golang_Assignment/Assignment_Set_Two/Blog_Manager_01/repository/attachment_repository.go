@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"blogmanager/model"
+	"database/sql"
+	"time"
+)
+
+type AttachmentRepository struct {
+	DB      *sql.DB
+	Dialect Dialect
+}
+
+func NewAttachmentRepository(db *sql.DB, dialect Dialect) *AttachmentRepository {
+	return &AttachmentRepository{DB: db, Dialect: dialect}
+}
+
+const attachmentColumns = "id, blog_id, hash, filename, content_type, size, created_at"
+
+func scanAttachment(row rowScanner) (*model.Attachment, error) {
+	attachment := &model.Attachment{}
+	err := row.Scan(&attachment.ID, &attachment.BlogID, &attachment.Hash, &attachment.Filename,
+		&attachment.ContentType, &attachment.Size, &attachment.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return attachment, nil
+}
+
+func (repo *AttachmentRepository) CreateAttachment(attachment *model.Attachment) (*model.Attachment, error) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	id, err := repo.Dialect.insert(repo.DB, `INSERT INTO attachments (blog_id, hash, filename, content_type, size, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		attachment.BlogID, attachment.Hash, attachment.Filename, attachment.ContentType, attachment.Size, now)
+	if err != nil {
+		return nil, err
+	}
+
+	attachment.ID = int(id)
+	attachment.CreatedAt = now
+	return attachment, nil
+}
+
+// GetAttachmentsByBlog returns the attachments of a blog, oldest first.
+func (repo *AttachmentRepository) GetAttachmentsByBlog(blogID int) ([]model.Attachment, error) {
+	rows, err := repo.Dialect.query(repo.DB, "SELECT "+attachmentColumns+" FROM attachments WHERE blog_id = ? ORDER BY id", blogID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := []model.Attachment{}
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, *attachment)
+	}
+	return attachments, rows.Err()
+}
+
+// GetMediaType returns the content type of the file with the given hash, as
+// long as it is attached to a blog the viewer may see.
+func (repo *AttachmentRepository) GetMediaType(hash string, viewer *model.User) (string, error) {
+	visible, visibleArgs := visibilityCondition(viewer)
+	var contentType string
+	err := repo.Dialect.queryRow(repo.DB, `SELECT content_type FROM attachments
+		WHERE hash = ? AND blog_id IN (SELECT id FROM blogs WHERE `+visible+`)
+		LIMIT 1`, append([]any{hash}, visibleArgs...)...).Scan(&contentType)
+	return contentType, err
+}
+
+// ReferencedHashes returns the hash of every file that is still attached to
+// a blog, in the trash or not.
+func (repo *AttachmentRepository) ReferencedHashes() (map[string]bool, error) {
+	rows, err := repo.Dialect.query(repo.DB, "SELECT DISTINCT hash FROM attachments")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hashes := make(map[string]bool)
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		hashes[hash] = true
+	}
+	return hashes, rows.Err()
+}
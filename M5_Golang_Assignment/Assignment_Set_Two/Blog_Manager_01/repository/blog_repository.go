@@ -3,96 +3,473 @@ package repository
 import (
 	"blogmanager/model"
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
+	"strings"
 	"time"
 )
 
+var ErrVersionConflict = errors.New("blog has been modified since it was read")
+
+// BlogRepository is the BlogStore backed by SQLite or PostgreSQL.
 type BlogRepository struct {
-	DB *sql.DB
+	DB      *sql.DB
+	Dialect Dialect
 }
 
-func NewBlogRepository(db *sql.DB) *BlogRepository {
-	return &BlogRepository{DB: db}
+func NewBlogRepository(db *sql.DB, dialect Dialect) *BlogRepository {
+	return &BlogRepository{DB: db, Dialect: dialect}
 }
 
-func (repo *BlogRepository) CreateBlog(blog *model.Blog) (*model.Blog, error) {
-	stmt, err := repo.DB.Prepare("INSERT INTO blogs (title, content, author, timestamp) VALUES (?, ?, ?, ?)")
+const blogColumns = "id, title, content, author, created_at, updated_at, version, author_id, status, publish_at, format, deleted_at"
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanBlog(row rowScanner) (*model.Blog, error) {
+	blog := &model.Blog{}
+	var authorID sql.NullInt64
+	var createdAt, updatedAt string
+	var publishAt, deletedAt sql.NullString
+	err := row.Scan(&blog.ID, &blog.Title, &blog.Content, &blog.Author, &createdAt, &updatedAt, &blog.Version,
+		&authorID, &blog.Status, &publishAt, &blog.Format, &deletedAt)
 	if err != nil {
 		return nil, err
 	}
-	defer stmt.Close()
+	blog.AuthorID = int(authorID.Int64)
+	if blog.CreatedAt, err = time.Parse(time.RFC3339, createdAt); err != nil {
+		return nil, fmt.Errorf("blog %d has invalid created_at: %v", blog.ID, err)
+	}
+	if blog.UpdatedAt, err = time.Parse(time.RFC3339, updatedAt); err != nil {
+		return nil, fmt.Errorf("blog %d has invalid updated_at: %v", blog.ID, err)
+	}
+	if publishAt.Valid {
+		t, err := time.Parse(time.RFC3339, publishAt.String)
+		if err != nil {
+			return nil, fmt.Errorf("blog %d has invalid publish_at: %v", blog.ID, err)
+		}
+		blog.PublishAt = &t
+	}
+	if deletedAt.Valid {
+		t, err := time.Parse(time.RFC3339, deletedAt.String)
+		if err != nil {
+			return nil, fmt.Errorf("blog %d has invalid deleted_at: %v", blog.ID, err)
+		}
+		blog.DeletedAt = &t
+	}
+	return blog, nil
+}
 
-	res, err := stmt.Exec(blog.Title, blog.Content, blog.Author, time.Now().String())
+// formatTime formats t for storage. RFC 3339 in UTC sorts chronologically
+// as text.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// nullableTime formats t for storage, mapping nil to NULL.
+func nullableTime(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return formatTime(*t)
+}
+
+// visibilityCondition restricts a query on blogs to the ones the viewer may
+// see: blogs outside the trash that are published or, for non-admins, their
+// own. A nil viewer only sees published blogs.
+func visibilityCondition(viewer *model.User) (string, []any) {
+	switch {
+	case viewer == nil:
+		return "deleted_at IS NULL AND status = ?", []any{model.StatusPublished}
+	case viewer.IsAdmin:
+		return "deleted_at IS NULL", nil
+	default:
+		return "deleted_at IS NULL AND (status = ? OR author_id = ?)", []any{model.StatusPublished, viewer.ID}
+	}
+}
+
+// CreateBlog inserts the blog and its tags in a single transaction.
+func (repo *BlogRepository) CreateBlog(blog *model.Blog) (*model.Blog, error) {
+	tx, err := repo.DB.Begin()
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback()
 
-	id, err := res.LastInsertId()
+	now := time.Now().UTC().Truncate(time.Second)
+	if blog.CreatedAt.IsZero() {
+		blog.CreatedAt = now
+	}
+	if blog.UpdatedAt.IsZero() {
+		blog.UpdatedAt = blog.CreatedAt
+	}
+	blog.CreatedAt = blog.CreatedAt.UTC().Truncate(time.Second)
+	blog.UpdatedAt = blog.UpdatedAt.UTC().Truncate(time.Second)
+	if blog.PublishAt != nil {
+		// Answer with the publish time as it is stored, like GetBlog
+		publishAt := blog.PublishAt.UTC().Truncate(time.Second)
+		blog.PublishAt = &publishAt
+	}
+	id, err := repo.Dialect.insert(tx, `INSERT INTO blogs (title, content, author, created_at, updated_at, author_id, status,
+		publish_at, format, rendered_html) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		blog.Title, blog.Content, blog.Author, formatTime(blog.CreatedAt), formatTime(blog.UpdatedAt), blog.AuthorID, blog.Status,
+		nullableTime(blog.PublishAt), blog.Format, blog.HTML)
 	if err != nil {
 		return nil, err
-
 	}
 
 	blog.ID = int(id)
+	blog.Version = 1
+	if blog.Tags == nil {
+		blog.Tags = []string{}
+	}
+	if err := repo.setTags(tx, blog.ID, blog.Tags); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 	return blog, nil
 }
 
 func (repo *BlogRepository) GetBlog(id int) (*model.Blog, error) {
-	row := repo.DB.QueryRow("SELECT id, title, content, author, timestamp FROM blogs WHERE id = ?", id)
-	blog := &model.Blog{}
-	err := row.Scan(&blog.ID, &blog.Title, &blog.Content, &blog.Author, &blog.TimeStamp)
+	row := repo.Dialect.queryRow(repo.DB, "SELECT "+blogColumns+" FROM blogs WHERE id = ? AND deleted_at IS NULL", id)
+	blog, err := scanBlog(row)
 	if err != nil {
 		return nil, err
 	}
-	return blog, nil
+
+	blogs := []model.Blog{*blog}
+	if err := repo.loadTags(blogs); err != nil {
+		return nil, err
+	}
+	return &blogs[0], nil
 }
 
-func (repo *BlogRepository) GetAllBlogs() ([]model.Blog, error) {
-	rows, err := repo.DB.Query("SELECT id, title, content, author, timestamp FROM blogs")
+// GetAllBlogs returns one page of blogs matching opts together with the
+// total number of matches and, when more blogs follow, the cursor of the
+// next page. opts.Limit and opts.Sort must already be validated.
+func (repo *BlogRepository) GetAllBlogs(opts model.BlogListOptions) (*model.BlogPage, error) {
+	visible, args := visibilityCondition(opts.Viewer)
+	where := []string{visible}
+	if opts.Status != "" {
+		where = append(where, "status = ?")
+		args = append(args, opts.Status)
+	}
+	if opts.Author != "" {
+		where = append(where, "author = ?")
+		args = append(args, opts.Author)
+	}
+	if opts.Tag != "" {
+		where = append(where, `id IN (SELECT bt.blog_id FROM blog_tags bt
+			JOIN tags t ON t.id = bt.tag_id WHERE t.name = ?)`)
+		args = append(args, opts.Tag)
+	}
+	if !opts.From.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, formatTime(opts.From))
+	}
+	if !opts.To.IsZero() {
+		where = append(where, "created_at <= ?")
+		args = append(args, formatTime(opts.To))
+	}
+
+	page := &model.BlogPage{Data: []model.Blog{}}
+	err := repo.Dialect.queryRow(repo.DB, "SELECT COUNT(*) FROM blogs"+whereClause(where), args...).Scan(&page.Total)
+	if err != nil {
+		return nil, err
+	}
+
+	var order string
+	// Break ties on the id so that every blog has a single place in the order
+	switch opts.Sort {
+	case model.SortOldest:
+		order = "created_at ASC, id ASC"
+	case model.SortTitle:
+		order = "title ASC, id ASC"
+	default:
+		order = "created_at DESC, id DESC"
+	}
+
+	if opts.After != "" {
+		cursor, err := decodeCursor(opts.After, opts.Sort)
+		if err != nil {
+			return nil, err
+		}
+		switch opts.Sort {
+		case model.SortOldest:
+			where = append(where, "(created_at > ? OR (created_at = ? AND id > ?))")
+			args = append(args, cursor.CreatedAt, cursor.CreatedAt, cursor.ID)
+		case model.SortTitle:
+			where = append(where, "(title > ? OR (title = ? AND id > ?))")
+			args = append(args, cursor.Title, cursor.Title, cursor.ID)
+		default:
+			where = append(where, "(created_at < ? OR (created_at = ? AND id < ?))")
+			args = append(args, cursor.CreatedAt, cursor.CreatedAt, cursor.ID)
+		}
+	}
+
+	// Fetch one extra row to find out whether another page follows
+	query := "SELECT " + blogColumns + " FROM blogs" + whereClause(where) + " ORDER BY " + order + " LIMIT ?"
+	rows, err := repo.Dialect.query(repo.DB, query, append(args, opts.Limit+1)...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var blogs []model.Blog
 	for rows.Next() {
-		var blog model.Blog
-		err := rows.Scan(&blog.ID, &blog.Title, &blog.Content, &blog.Author, &blog.TimeStamp)
+		blog, err := scanBlog(rows)
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
-		blogs = append(blogs, blog)
+		page.Data = append(page.Data, *blog)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.Data) > opts.Limit {
+		page.Data = page.Data[:opts.Limit]
+		page.NextCursor = nextCursor(opts.Sort, &page.Data[len(page.Data)-1])
+	}
+
+	if err := repo.loadTags(page.Data); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
 	}
-	return blogs, nil
+	return " WHERE " + strings.Join(conditions, " AND ")
 }
 
+// UpdateBlog records the current version of the blog as a revision, then
+// updates it and, unless blog.Tags is nil, replaces its tags, all in a single
+// transaction.
 func (repo *BlogRepository) UpdateBlog(blog *model.Blog) (*model.Blog, error) {
-	stmt, err := repo.DB.Prepare("UPDATE blogs SET title = ?, content = ?, author = ?, timestamp = ? WHERE id = ?")
+	tx, err := repo.DB.Begin()
 	if err != nil {
 		return nil, err
 	}
-	defer stmt.Close()
+	defer tx.Rollback()
 
-	_, err = stmt.Exec(blog.Title, blog.Content, blog.Author, time.Now().String(), blog.ID)
-	if err != nil {
+	if err := repo.saveRevision(tx, blog.ID); err != nil {
 		return nil, err
 	}
-	fmt.Println("Successfully updated blog with ID:", blog.ID)
-	return blog, nil
+
+	query := `UPDATE blogs SET title = ?, content = ?, author = ?, updated_at = ?, version = version + 1, status = ?,
+		publish_at = ?, format = ?, rendered_html = ? WHERE id = ? AND deleted_at IS NULL`
+	args := []any{blog.Title, blog.Content, blog.Author, formatTime(time.Now()), blog.Status, nullableTime(blog.PublishAt),
+		blog.Format, blog.HTML, blog.ID}
+	if blog.Version != 0 {
+		query += " AND version = ?"
+		args = append(args, blog.Version)
+	}
+	res, err := repo.Dialect.exec(tx, query, args...)
+	if err := checkVersionedWrite(res, err, blog.Version); err != nil {
+		return nil, err
+	}
+
+	if blog.Tags != nil {
+		if err := repo.setTags(tx, blog.ID, blog.Tags); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return repo.GetBlog(blog.ID)
 }
 
-func (repo *BlogRepository) DeleteBlog(id int) error {
-	stmt, err := repo.DB.Prepare("DELETE FROM blogs WHERE id = ?")
+// DeleteBlog moves a blog to the trash.
+func (repo *BlogRepository) DeleteBlog(id, version int) error {
+	now := formatTime(time.Now())
+	query := "UPDATE blogs SET deleted_at = ?, updated_at = ?, version = version + 1 WHERE id = ? AND deleted_at IS NULL"
+	args := []any{now, now, id}
+	if version != 0 {
+		query += " AND version = ?"
+		args = append(args, version)
+	}
+	res, err := repo.Dialect.exec(repo.DB, query, args...)
+	return checkVersionedWrite(res, err, version)
+}
+
+// checkVersionedWrite checks that an UPDATE or DELETE of one blog at the
+// given version matched a row. Without a version a missing row means the
+// blog does not exist; with one, the blog is assumed to have moved on.
+func checkVersionedWrite(res sql.Result, err error, version int) error {
 	if err != nil {
 		return err
 	}
-	defer stmt.Close()
-
-	_, err = stmt.Exec(id)
+	n, err := res.RowsAffected()
 	if err != nil {
 		return err
 	}
-	fmt.Println("Successfully deleted blog with ID:", id)
-	return nil
+	switch {
+	case n > 0:
+		return nil
+	case version == 0:
+		return sql.ErrNoRows
+	default:
+		return ErrVersionConflict
+	}
+}
+
+// GetRenderedHTML returns the cached HTML rendering of a blog, which is empty
+// for blogs written before rendering was introduced.
+func (repo *BlogRepository) GetRenderedHTML(id int) (string, error) {
+	var rendered sql.NullString
+	err := repo.Dialect.queryRow(repo.DB, "SELECT rendered_html FROM blogs WHERE id = ?", id).Scan(&rendered)
+	return rendered.String, err
+}
+
+func (repo *BlogRepository) SaveRenderedHTML(id int, rendered string) error {
+	_, err := repo.Dialect.exec(repo.DB, "UPDATE blogs SET rendered_html = ? WHERE id = ?", rendered, id)
+	return err
+}
+
+// PublishDueBlogs publishes every scheduled blog whose publish time is at or
+// before now and returns how many were published.
+func (repo *BlogRepository) PublishDueBlogs(now time.Time) (int, error) {
+	res, err := repo.Dialect.exec(repo.DB, `UPDATE blogs SET status = ?, updated_at = ?, version = version + 1
+		WHERE status = ? AND publish_at <= ? AND deleted_at IS NULL`,
+		model.StatusPublished, formatTime(now), model.StatusScheduled, now.UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0, err
+	}
+
+	published, err := res.RowsAffected()
+	return int(published), err
+}
+
+// LastChanged relies on every write to a blog, trashing included, setting
+// its updated_at.
+func (repo *BlogRepository) LastChanged(author string) (time.Time, error) {
+	query := "SELECT MAX(updated_at) FROM blogs"
+	var args []any
+	if author != "" {
+		query += " WHERE author = ?"
+		args = append(args, author)
+	}
+	var updatedAt sql.NullString
+	if err := repo.Dialect.queryRow(repo.DB, query, args...).Scan(&updatedAt); err != nil {
+		return time.Time{}, err
+	}
+	if !updatedAt.Valid {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, updatedAt.String)
+}
+
+// Search finds the blogs visible to viewer whose title or content contain
+// every term, best matches first. Title matches weigh ten times more than
+// content ones. Rank is lower for better matches.
+func (repo *BlogRepository) Search(terms []SearchTerm, limit int, viewer *model.User) ([]model.BlogSearchResult, error) {
+	visible, visibleArgs := visibilityCondition(viewer)
+
+	var query string
+	var args []any
+	if repo.Dialect == Postgres {
+		query = `SELECT b.id, b.title, b.author, b.author_id, b.created_at, b.updated_at,
+				ts_headline('simple', b.title, q, ?),
+				ts_headline('simple', b.content, q, ?),
+				-ts_rank(` + searchDocument + `, q) AS rank
+			FROM blogs b, to_tsquery('simple', ?) q
+			WHERE ` + searchDocument + ` @@ q AND ` + visible + `
+			ORDER BY rank
+			LIMIT ?`
+		args = append([]any{
+			"StartSel=" + highlightStart + ", StopSel=" + highlightStop + ", HighlightAll=true",
+			"StartSel=" + highlightStart + ", StopSel=" + highlightStop + ", MaxFragments=1, MaxWords=16",
+			tsQuery(terms),
+		}, visibleArgs...)
+	} else {
+		query = `SELECT b.id, b.title, b.author, b.author_id, b.created_at, b.updated_at,
+				highlight(blogs_fts, 0, ?, ?),
+				snippet(blogs_fts, 1, ?, ?, '…', ?),
+				bm25(blogs_fts, 10.0, 1.0) AS rank
+			FROM blogs_fts
+			JOIN blogs b ON b.id = blogs_fts.rowid
+			WHERE blogs_fts MATCH ? AND ` + visible + `
+			ORDER BY rank
+			LIMIT ?`
+		args = append([]any{highlightStart, highlightStop, highlightStart, highlightStop, snippetWords,
+			matchExpression(terms)}, visibleArgs...)
+	}
+
+	rows, err := repo.Dialect.query(repo.DB, query, append(args, limit)...)
+	if err != nil {
+		if strings.Contains(err.Error(), "no such table: blogs_fts") {
+			return repo.searchWithoutIndex(terms, limit, viewer)
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []model.BlogSearchResult{}
+	for rows.Next() {
+		var result model.BlogSearchResult
+		var authorID sql.NullInt64
+		var createdAt, updatedAt string
+		err := rows.Scan(&result.ID, &result.Title, &result.Author, &authorID, &createdAt, &updatedAt,
+			&result.TitleHighlight, &result.Snippet, &result.Rank)
+		if err != nil {
+			return nil, err
+		}
+		result.AuthorID = int(authorID.Int64)
+		result.TitleHighlight, result.Snippet = markHighlights(result.TitleHighlight), markHighlights(result.Snippet)
+		if result.CreatedAt, err = time.Parse(time.RFC3339, createdAt); err != nil {
+			return nil, fmt.Errorf("blog %d has invalid created_at: %v", result.ID, err)
+		}
+		if result.UpdatedAt, err = time.Parse(time.RFC3339, updatedAt); err != nil {
+			return nil, fmt.Errorf("blog %d has invalid updated_at: %v", result.ID, err)
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
 }
+
+// searchWithoutIndex searches SQLite databases without the blogs_fts index,
+// which needs FTS5, by narrowing the blogs down with LIKE and matching them in
+// Go. It finds the same blogs, ranked and highlighted less finely.
+func (repo *BlogRepository) searchWithoutIndex(terms []SearchTerm, limit int, viewer *model.User) ([]model.BlogSearchResult, error) {
+	visible, args := visibilityCondition(viewer)
+	where := []string{visible}
+	for _, pattern := range likePatterns(terms) {
+		where = append(where, "(title LIKE ? OR content LIKE ?)")
+		args = append(args, pattern, pattern)
+	}
+
+	rows, err := repo.Dialect.query(repo.DB, "SELECT "+blogColumns+" FROM blogs"+whereClause(where), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []model.BlogSearchResult{}
+	for rows.Next() {
+		blog, err := scanBlog(rows)
+		if err != nil {
+			return nil, err
+		}
+		if result, ok := matchBlog(blog, terms); ok {
+			results = append(results, result)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return rankResults(results, limit), nil
+}
+
+// searchDocument is the weighted tsvector of a blog that PostgreSQL searches.
+// It must match the expression of the idx_blogs_search index.
+const searchDocument = `(setweight(to_tsvector('simple', b.title), 'A') ||
+	setweight(to_tsvector('simple', b.content), 'D'))`
@@ -0,0 +1,48 @@
+package repository_test
+
+import (
+	"blogmanager/config/dbtest"
+	"blogmanager/model"
+	"blogmanager/repository"
+	"strings"
+	"testing"
+)
+
+// TestSearchEscapesHighlights runs against FTS5 when built with the
+// sqlite_fts5 tag and against the LIKE fallback otherwise.
+func TestSearchEscapesHighlights(t *testing.T) {
+	database := dbtest.Open(t)
+	author := createTestUser(t, database, repository.SQLite, "alice")
+	repo := repository.NewBlogRepository(database, repository.SQLite)
+
+	for _, blog := range []*model.Blog{
+		{Title: `<img src=x onerror="alert(1)"> payload`, Content: "<script>alert('payload')</script>"},
+		{Title: "Unrelated", Content: "Nothing to see"},
+	} {
+		blog.Author, blog.AuthorID = author.Username, author.ID
+		blog.Status, blog.Format = model.StatusPublished, model.FormatPlain
+		if _, err := repo.CreateBlog(blog); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := repo.Search(repository.ParseSearchQuery("payload"), 10, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Search found %d blogs, want 1", len(results))
+	}
+	result := results[0]
+	for name, html := range map[string]string{"title_highlight": result.TitleHighlight, "snippet": result.Snippet} {
+		if strings.Contains(html, "<script") || strings.Contains(html, "<img") {
+			t.Errorf("%s holds unescaped HTML: %q", name, html)
+		}
+		if !strings.Contains(html, "<mark>payload</mark>") {
+			t.Errorf("%s does not mark the match: %q", name, html)
+		}
+	}
+	if want := "&lt;img src=x onerror=&#34;alert(1)&#34;&gt; <mark>payload</mark>"; result.TitleHighlight != want {
+		t.Errorf("title_highlight = %q, want %q", result.TitleHighlight, want)
+	}
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"blogmanager/model"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const revisionColumns = "blog_id, revision, title, content, status, tags, created_at"
+
+// saveRevision copies the current title, content, status and tags of a blog
+// into blog_revisions under the next revision number.
+func (repo *BlogRepository) saveRevision(tx *sql.Tx, blogID int) error {
+	tags, err := repo.blogTags(tx, blogID)
+	if err != nil {
+		return err
+	}
+	encodedTags, err := json.Marshal(tags)
+	if err != nil {
+		return err
+	}
+
+	_, err = repo.Dialect.exec(tx, `INSERT INTO blog_revisions (`+revisionColumns+`)
+		SELECT id, (SELECT COALESCE(MAX(revision), 0) + 1 FROM blog_revisions WHERE blog_id = ?),
+			title, content, status, ?, ?
+		FROM blogs WHERE id = ?`, blogID, string(encodedTags), time.Now().UTC().Format(time.RFC3339), blogID)
+	return err
+}
+
+// scanRevision reads a row of revisionColumns. Revisions saved before status
+// and tags were recorded come without them.
+func scanRevision(row rowScanner) (*model.BlogRevision, error) {
+	rev := &model.BlogRevision{}
+	var status, tags sql.NullString
+	err := row.Scan(&rev.BlogID, &rev.Revision, &rev.Title, &rev.Content, &status, &tags, &rev.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	rev.Status = status.String
+	if tags.Valid {
+		if err := json.Unmarshal([]byte(tags.String), &rev.Tags); err != nil {
+			return nil, fmt.Errorf("revision %d of blog %d has invalid tags: %v", rev.Revision, rev.BlogID, err)
+		}
+	}
+	return rev, nil
+}
+
+// GetRevisions returns every saved revision of a blog, newest first.
+func (repo *BlogRepository) GetRevisions(blogID int) ([]model.BlogRevision, error) {
+	rows, err := repo.Dialect.query(repo.DB, `SELECT `+revisionColumns+` FROM blog_revisions
+		WHERE blog_id = ? ORDER BY revision DESC`, blogID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []model.BlogRevision{}
+	for rows.Next() {
+		revision, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, *revision)
+	}
+	return revisions, rows.Err()
+}
+
+func (repo *BlogRepository) GetRevision(blogID, revision int) (*model.BlogRevision, error) {
+	row := repo.Dialect.queryRow(repo.DB, `SELECT `+revisionColumns+` FROM blog_revisions
+		WHERE blog_id = ? AND revision = ?`, blogID, revision)
+	return scanRevision(row)
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"blogmanager/model"
+	"time"
+)
+
+// BlogStore persists blogs with their tags and revisions. Deleted blogs go to
+// the trash, where only the trash methods see them. Lookups of a blog or
+// revision that does not exist fail with sql.ErrNoRows. Writes that
+// expect a version, 0 meaning any, fail with ErrVersionConflict when the
+// blog is at another one.
+type BlogStore interface {
+	// CreateBlog stores a new blog and sets its ID, and its timestamps unless
+	// they are set already, as on blogs being imported.
+	CreateBlog(blog *model.Blog) (*model.Blog, error)
+	GetBlog(id int) (*model.Blog, error)
+	// GetAllBlogs returns one page of blogs; opts must already be validated.
+	GetAllBlogs(opts model.BlogListOptions) (*model.BlogPage, error)
+	// UpdateBlog saves the current version as a revision before updating
+	// the blog, which must be at blog.Version. Tags are left alone when
+	// blog.Tags is nil.
+	UpdateBlog(blog *model.Blog) (*model.Blog, error)
+	// DeleteBlog moves a blog to the trash.
+	DeleteBlog(id, version int) error
+
+	GetRenderedHTML(id int) (string, error)
+	SaveRenderedHTML(id int, rendered string) error
+	// PublishDueBlogs publishes the scheduled blogs due at now.
+	PublishDueBlogs(now time.Time) (int, error)
+	// LastChanged returns when a blog of author, or of anyone when author is
+	// empty, was last created, updated, published, trashed or restored, and
+	// the zero time when there are none.
+	LastChanged(author string) (time.Time, error)
+
+	Search(terms []SearchTerm, limit int, viewer *model.User) ([]model.BlogSearchResult, error)
+	GetTags() ([]model.Tag, error)
+
+	GetRevisions(blogID int) ([]model.BlogRevision, error)
+	GetRevision(blogID, revision int) (*model.BlogRevision, error)
+
+	// GetTrash returns the trashed blogs of user, or all of them for an
+	// admin, most recently deleted first.
+	GetTrash(user *model.User) ([]model.Blog, error)
+	GetTrashedBlog(id int) (*model.Blog, error)
+	RestoreBlog(id int) error
+	// PurgeTrash permanently deletes the blogs trashed at or before before.
+	PurgeTrash(before time.Time) (int, error)
+}
+
+var (
+	_ BlogStore = (*BlogRepository)(nil)
+	_ BlogStore = (*MemoryBlogStore)(nil)
+)
@@ -0,0 +1,401 @@
+package repository_test
+
+import (
+	"blogmanager/cache"
+	"blogmanager/config/dbtest"
+	"blogmanager/model"
+	"blogmanager/repository"
+	"database/sql"
+	"errors"
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+// storeFixture is an empty BlogStore with two users to own blogs.
+type storeFixture struct {
+	Store      repository.BlogStore
+	Alice, Bob *model.User
+}
+
+// blogStores returns a constructor of an empty store of every kind, and of
+// the memory store behind a cache. The PostgreSQL store is only tested when
+// BLOG_TEST_POSTGRES_DSN names a database, which the tests empty.
+func blogStores(t *testing.T) map[string]func(t *testing.T) storeFixture {
+	stores := map[string]func(t *testing.T) storeFixture{
+		"memory": func(t *testing.T) storeFixture {
+			return storeFixture{
+				Store: repository.NewMemoryBlogStore(),
+				Alice: &model.User{ID: 1, Username: "alice"},
+				Bob:   &model.User{ID: 2, Username: "bob"},
+			}
+		},
+		"cached": func(t *testing.T) storeFixture {
+			return storeFixture{
+				Store: repository.NewCachedBlogStore(repository.NewMemoryBlogStore(), cache.NewLRU(100), time.Minute),
+				Alice: &model.User{ID: 1, Username: "alice"},
+				Bob:   &model.User{ID: 2, Username: "bob"},
+			}
+		},
+		"sqlite": func(t *testing.T) storeFixture {
+			database := dbtest.Open(t)
+			return storeFixture{
+				Store: repository.NewBlogRepository(database, repository.SQLite),
+				Alice: createTestUser(t, database, repository.SQLite, "alice"),
+				Bob:   createTestUser(t, database, repository.SQLite, "bob"),
+			}
+		},
+	}
+	if dsn := os.Getenv("BLOG_TEST_POSTGRES_DSN"); dsn != "" {
+		stores["postgres"] = func(t *testing.T) storeFixture {
+			database := dbtest.OpenPostgres(t, dsn)
+			return storeFixture{
+				Store: repository.NewBlogRepository(database, repository.Postgres),
+				Alice: createTestUser(t, database, repository.Postgres, "alice"),
+				Bob:   createTestUser(t, database, repository.Postgres, "bob"),
+			}
+		}
+	}
+	return stores
+}
+
+// createTestBlog stores a published blog by author.
+func createTestBlog(t *testing.T, store repository.BlogStore, author *model.User, blog model.Blog) *model.Blog {
+	t.Helper()
+	blog.Author, blog.AuthorID = author.Username, author.ID
+	if blog.Content == "" {
+		blog.Content = "Content"
+	}
+	if blog.Status == "" {
+		blog.Status = model.StatusPublished
+	}
+	blog.Format = model.FormatMarkdown
+	created, err := store.CreateBlog(&blog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return created
+}
+
+// TestListOrderFollowsCreationTime stores blogs whose ids are not in the
+// order they were created in, as imports do, and pages through them.
+func TestListOrderFollowsCreationTime(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 12, 0, 0, 0, time.UTC) }
+	for name, newStore := range blogStores(t) {
+		t.Run(name, func(t *testing.T) {
+			f := newStore(t)
+			store, author := f.Store, f.Alice
+			for _, blog := range []model.Blog{
+				{Title: "c", CreatedAt: day(3)},
+				{Title: "a", CreatedAt: day(1)},
+				{Title: "b1", CreatedAt: day(2)},
+				{Title: "b2", CreatedAt: day(2)},
+				{Title: "d", CreatedAt: day(4)},
+			} {
+				createTestBlog(t, store, author, blog)
+			}
+
+			tests := []struct {
+				sort     string
+				from, to time.Time
+				want     []string
+			}{
+				{model.SortNewest, time.Time{}, time.Time{}, []string{"d", "c", "b2", "b1", "a"}},
+				{model.SortOldest, time.Time{}, time.Time{}, []string{"a", "b1", "b2", "c", "d"}},
+				{model.SortTitle, time.Time{}, time.Time{}, []string{"a", "b1", "b2", "c", "d"}},
+				{model.SortNewest, day(2), day(3), []string{"c", "b2", "b1"}},
+				{model.SortOldest, day(2), time.Time{}, []string{"b1", "b2", "c", "d"}},
+			}
+			for _, tt := range tests {
+				// One blog per page walks every cursor
+				opts := model.BlogListOptions{Limit: 1, Sort: tt.sort, From: tt.from, To: tt.to}
+				var got []string
+				for {
+					page, err := store.GetAllBlogs(opts)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if page.Total != len(tt.want) {
+						t.Errorf("%s: total = %d, want %d", tt.sort, page.Total, len(tt.want))
+					}
+					for _, blog := range page.Data {
+						got = append(got, blog.Title)
+					}
+					if page.NextCursor == "" || len(got) > len(tt.want) {
+						break
+					}
+					opts.After = page.NextCursor
+				}
+				if !slices.Equal(got, tt.want) {
+					t.Errorf("%s from %s to %s = %v, want %v", tt.sort, tt.from, tt.to, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateBlogReturnsStoredPublishTime(t *testing.T) {
+	publishAt := time.Date(2024, 1, 1, 12, 0, 0, 123456789, time.FixedZone("CET", 3600))
+	for name, newStore := range blogStores(t) {
+		t.Run(name, func(t *testing.T) {
+			f := newStore(t)
+			store, author := f.Store, f.Alice
+			created := createTestBlog(t, store, author, model.Blog{Title: "Title", PublishAt: &publishAt})
+			stored, err := store.GetBlog(created.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !created.PublishAt.Equal(*stored.PublishAt) || created.PublishAt.Location() != time.UTC {
+				t.Errorf("CreateBlog returned publish_at %s, GetBlog %s", created.PublishAt, stored.PublishAt)
+			}
+			if publishAt.Nanosecond() != 123456789 {
+				t.Error("CreateBlog changed the caller's publish time")
+			}
+		})
+	}
+}
+
+func TestBlogStoreLifecycle(t *testing.T) {
+	for name, newStore := range blogStores(t) {
+		t.Run(name, func(t *testing.T) {
+			f := newStore(t)
+			store := f.Store
+			created := createTestBlog(t, store, f.Alice, model.Blog{Title: "First", Tags: []string{"go", "web"}})
+			if created.ID == 0 || created.Version != 1 {
+				t.Fatalf("CreateBlog returned id %d version %d", created.ID, created.Version)
+			}
+			if _, err := store.GetBlog(created.ID + 100); !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("GetBlog of a missing blog = %v, want sql.ErrNoRows", err)
+			}
+
+			got, err := store.GetBlog(created.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Title != "First" || got.Content != "Content" || got.AuthorID != f.Alice.ID ||
+				got.Status != model.StatusPublished || got.Format != model.FormatMarkdown || !sameTags(got.Tags, "go", "web") {
+				t.Errorf("GetBlog = %+v, not the blog created", got)
+			}
+
+			// Updates save the replaced version and leave nil tags alone
+			update := *got
+			update.Title, update.Tags = "Second", nil
+			updated, err := store.UpdateBlog(&update)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if updated.Title != "Second" || updated.Version != 2 || !sameTags(updated.Tags, "go", "web") {
+				t.Errorf("UpdateBlog = %+v", updated)
+			}
+			if _, err := store.UpdateBlog(&update); !errors.Is(err, repository.ErrVersionConflict) {
+				t.Errorf("UpdateBlog at a stale version = %v, want ErrVersionConflict", err)
+			}
+			update.Version, update.Tags = 0, []string{}
+			if updated, err = store.UpdateBlog(&update); err != nil || len(updated.Tags) != 0 || updated.Version != 3 {
+				t.Errorf("UpdateBlog at any version = %+v, %v", updated, err)
+			}
+
+			revisions, err := store.GetRevisions(created.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(revisions) != 2 || revisions[0].Revision != 2 || revisions[1].Title != "First" {
+				t.Errorf("GetRevisions = %+v, want revisions 2 and 1, newest first", revisions)
+			}
+			rev, err := store.GetRevision(created.ID, 1)
+			if err != nil || rev.Title != "First" || rev.Status != model.StatusPublished || !slices.Equal(rev.Tags, []string{"go", "web"}) {
+				t.Errorf("GetRevision(1) = %+v, %v", rev, err)
+			}
+			if rev, err := store.GetRevision(created.ID, 2); err != nil || rev.Tags == nil {
+				t.Errorf("GetRevision(2) = %+v, %v, want tags recorded", rev, err)
+			}
+			if _, err := store.GetRevision(created.ID, 3); !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("GetRevision of a missing revision = %v, want sql.ErrNoRows", err)
+			}
+
+			if err := store.SaveRenderedHTML(created.ID, "<p>Content</p>"); err != nil {
+				t.Fatal(err)
+			}
+			if html, err := store.GetRenderedHTML(created.ID); err != nil || html != "<p>Content</p>" {
+				t.Errorf("GetRenderedHTML = %q, %v", html, err)
+			}
+
+			// Deleting moves the blog to the trash, from where it can come back
+			if err := store.DeleteBlog(created.ID, 1); !errors.Is(err, repository.ErrVersionConflict) {
+				t.Errorf("DeleteBlog at a stale version = %v, want ErrVersionConflict", err)
+			}
+			if err := store.DeleteBlog(created.ID, 3); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := store.GetBlog(created.ID); !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("GetBlog of a trashed blog = %v, want sql.ErrNoRows", err)
+			}
+			if err := store.DeleteBlog(created.ID, 0); !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("DeleteBlog of a trashed blog = %v, want sql.ErrNoRows", err)
+			}
+			if trashed, err := store.GetTrashedBlog(created.ID); err != nil || trashed.DeletedAt == nil {
+				t.Errorf("GetTrashedBlog = %+v, %v", trashed, err)
+			}
+			for _, tt := range []struct {
+				user *model.User
+				want int
+			}{{f.Alice, 1}, {f.Bob, 0}, {&model.User{ID: f.Bob.ID, IsAdmin: true}, 1}} {
+				if trash, err := store.GetTrash(tt.user); err != nil || len(trash) != tt.want {
+					t.Errorf("GetTrash(%d) holds %d blogs, want %d (%v)", tt.user.ID, len(trash), tt.want, err)
+				}
+			}
+
+			if err := store.RestoreBlog(created.ID); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.RestoreBlog(created.ID); !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("RestoreBlog of a blog outside the trash = %v, want sql.ErrNoRows", err)
+			}
+			if restored, err := store.GetBlog(created.ID); err != nil || restored.Version != 5 {
+				t.Errorf("GetBlog after restore = %+v, %v", restored, err)
+			}
+
+			if err := store.DeleteBlog(created.ID, 0); err != nil {
+				t.Fatal(err)
+			}
+			if purged, err := store.PurgeTrash(time.Now().Add(-time.Hour)); err != nil || purged != 0 {
+				t.Errorf("PurgeTrash of older blogs = %d, %v, want 0", purged, err)
+			}
+			if purged, err := store.PurgeTrash(time.Now().Add(time.Second)); err != nil || purged != 1 {
+				t.Errorf("PurgeTrash = %d, %v, want 1", purged, err)
+			}
+			if _, err := store.GetTrashedBlog(created.ID); !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("GetTrashedBlog of a purged blog = %v, want sql.ErrNoRows", err)
+			}
+		})
+	}
+}
+
+func TestBlogStoreVisibility(t *testing.T) {
+	for name, newStore := range blogStores(t) {
+		t.Run(name, func(t *testing.T) {
+			f := newStore(t)
+			store := f.Store
+			createTestBlog(t, store, f.Alice, model.Blog{Title: "Published go", Tags: []string{"go"}})
+			createTestBlog(t, store, f.Alice, model.Blog{Title: "Draft go", Tags: []string{"go"}, Status: model.StatusDraft})
+			createTestBlog(t, store, f.Bob, model.Blog{Title: "Bob's", Tags: []string{"web"}})
+			trashed := createTestBlog(t, store, f.Bob, model.Blog{Title: "Trashed go", Tags: []string{"go"}})
+			if err := store.DeleteBlog(trashed.ID, 0); err != nil {
+				t.Fatal(err)
+			}
+
+			admin := &model.User{ID: 99, IsAdmin: true}
+			tests := []struct {
+				name string
+				opts model.BlogListOptions
+				want int
+			}{
+				{"anonymous", model.BlogListOptions{}, 2},
+				{"other user", model.BlogListOptions{Viewer: f.Bob}, 2},
+				{"author", model.BlogListOptions{Viewer: f.Alice}, 3},
+				{"admin", model.BlogListOptions{Viewer: admin}, 3},
+				{"status", model.BlogListOptions{Viewer: f.Alice, Status: model.StatusDraft}, 1},
+				{"status hidden from others", model.BlogListOptions{Viewer: f.Bob, Status: model.StatusDraft}, 0},
+				{"author filter", model.BlogListOptions{Viewer: admin, Author: "bob"}, 1},
+				{"tag filter", model.BlogListOptions{Viewer: admin, Tag: "go"}, 2},
+			}
+			for _, tt := range tests {
+				tt.opts.Limit, tt.opts.Sort = 10, model.SortNewest
+				page, err := store.GetAllBlogs(tt.opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if page.Total != tt.want || len(page.Data) != tt.want {
+					t.Errorf("%s: listed %d of %d blogs, want %d", tt.name, len(page.Data), page.Total, tt.want)
+				}
+			}
+
+			tags, err := store.GetTags()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []model.Tag{{Name: "go", PostCount: 1}, {Name: "web", PostCount: 1}}
+			if !slices.Equal(tags, want) {
+				t.Errorf("GetTags = %v, want %v", tags, want)
+			}
+
+			for _, tt := range []struct {
+				viewer *model.User
+				want   int
+			}{{nil, 1}, {f.Bob, 1}, {f.Alice, 2}, {admin, 2}} {
+				results, err := store.Search(repository.ParseSearchQuery("go"), 10, tt.viewer)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(results) != tt.want {
+					t.Errorf("Search as %v found %d blogs, want %d", tt.viewer, len(results), tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestBlogStorePublishDueBlogs(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	past, future := now.Add(-time.Minute), now.Add(time.Hour)
+	for name, newStore := range blogStores(t) {
+		t.Run(name, func(t *testing.T) {
+			f := newStore(t)
+			store := f.Store
+			due := createTestBlog(t, store, f.Alice, model.Blog{Title: "Due", Status: model.StatusScheduled, PublishAt: &past,
+				CreatedAt: past, UpdatedAt: past})
+			later := createTestBlog(t, store, f.Alice, model.Blog{Title: "Later", Status: model.StatusScheduled, PublishAt: &future})
+
+			published, err := store.PublishDueBlogs(now)
+			if err != nil || published != 1 {
+				t.Fatalf("PublishDueBlogs = %d, %v, want 1", published, err)
+			}
+			if blog, err := store.GetBlog(due.ID); err != nil || blog.Status != model.StatusPublished || blog.Version != 2 ||
+				!blog.UpdatedAt.Equal(now) {
+				t.Errorf("due blog = %+v, %v, want published at version 2 and updated now", blog, err)
+			}
+			if blog, err := store.GetBlog(later.ID); err != nil || blog.Status != model.StatusScheduled {
+				t.Errorf("later blog = %+v, %v, want still scheduled", blog, err)
+			}
+		})
+	}
+}
+
+func TestBlogStoreLastChanged(t *testing.T) {
+	hourAgo := time.Now().UTC().Truncate(time.Second).Add(-time.Hour)
+	dayAgo := hourAgo.Add(-23 * time.Hour)
+	for name, newStore := range blogStores(t) {
+		t.Run(name, func(t *testing.T) {
+			f := newStore(t)
+			store := f.Store
+			if last, err := store.LastChanged(""); err != nil || !last.IsZero() {
+				t.Fatalf("LastChanged of an empty store = %v, %v, want zero", last, err)
+			}
+
+			createTestBlog(t, store, f.Alice, model.Blog{Title: "Alice's", CreatedAt: hourAgo, UpdatedAt: hourAgo})
+			bobs := createTestBlog(t, store, f.Bob, model.Blog{Title: "Bob's", CreatedAt: dayAgo, UpdatedAt: dayAgo})
+			for author, want := range map[string]time.Time{"": hourAgo, "alice": hourAgo, "bob": dayAgo} {
+				if last, err := store.LastChanged(author); err != nil || !last.Equal(want) {
+					t.Errorf("LastChanged(%q) = %v, %v, want %v", author, last, err, want)
+				}
+			}
+
+			// Trashed blogs leave the listings, so trashing has to count
+			// as a change of them
+			if err := store.DeleteBlog(bobs.ID, 0); err != nil {
+				t.Fatal(err)
+			}
+			if last, err := store.LastChanged("bob"); err != nil || !last.After(hourAgo) {
+				t.Errorf("LastChanged after trashing = %v, %v, want about now", last, err)
+			}
+			if last, err := store.LastChanged("alice"); err != nil || !last.Equal(hourAgo) {
+				t.Errorf("LastChanged of another author after trashing = %v, %v, want %v", last, err, hourAgo)
+			}
+		})
+	}
+}
+
+func sameTags(tags []string, want ...string) bool {
+	return slices.Equal(slices.Sorted(slices.Values(tags)), want)
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"blogmanager/model"
+	"database/sql"
+	"strings"
+)
+
+// setTags replaces the tags of a blog, creating tags that do not exist yet.
+// Tag names must already be normalized.
+func (repo *BlogRepository) setTags(tx *sql.Tx, blogID int, tags []string) error {
+	if _, err := repo.Dialect.exec(tx, "DELETE FROM blog_tags WHERE blog_id = ?", blogID); err != nil {
+		return err
+	}
+
+	for _, name := range tags {
+		if _, err := repo.Dialect.exec(tx, "INSERT INTO tags (name) VALUES (?) ON CONFLICT DO NOTHING", name); err != nil {
+			return err
+		}
+		_, err := repo.Dialect.exec(tx, `INSERT INTO blog_tags (blog_id, tag_id)
+			SELECT CAST(? AS INTEGER), id FROM tags WHERE name = ?
+			ON CONFLICT DO NOTHING`, blogID, name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadTags fills in the Tags of every blog in place with a single query.
+func (repo *BlogRepository) loadTags(blogs []model.Blog) error {
+	if len(blogs) == 0 {
+		return nil
+	}
+
+	placeholders := make([]string, len(blogs))
+	args := make([]any, len(blogs))
+	index := make(map[int]int, len(blogs))
+	for i := range blogs {
+		placeholders[i] = "?"
+		args[i] = blogs[i].ID
+		index[blogs[i].ID] = i
+		blogs[i].Tags = []string{}
+	}
+
+	rows, err := repo.Dialect.query(repo.DB, `SELECT bt.blog_id, t.name FROM blog_tags bt
+		JOIN tags t ON t.id = bt.tag_id
+		WHERE bt.blog_id IN (`+strings.Join(placeholders, ", ")+`)
+		ORDER BY t.name`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var blogID int
+		var name string
+		if err := rows.Scan(&blogID, &name); err != nil {
+			return err
+		}
+		i := index[blogID]
+		blogs[i].Tags = append(blogs[i].Tags, name)
+	}
+	return rows.Err()
+}
+
+// blogTags returns the tags of a blog by name.
+func (repo *BlogRepository) blogTags(q queryer, blogID int) ([]string, error) {
+	rows, err := repo.Dialect.query(q, `SELECT t.name FROM blog_tags bt
+		JOIN tags t ON t.id = bt.tag_id
+		WHERE bt.blog_id = ?
+		ORDER BY t.name`, blogID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tags = append(tags, name)
+	}
+	return tags, rows.Err()
+}
+
+// GetTags returns every tag used by a published blog with the number of
+// published blogs carrying it, most used first.
+func (repo *BlogRepository) GetTags() ([]model.Tag, error) {
+	rows, err := repo.Dialect.query(repo.DB, `SELECT t.name, COUNT(*) AS post_count FROM tags t
+		JOIN blog_tags bt ON bt.tag_id = t.id
+		JOIN blogs b ON b.id = bt.blog_id
+		WHERE b.status = ? AND b.deleted_at IS NULL
+		GROUP BY t.id
+		ORDER BY post_count DESC, t.name`, model.StatusPublished)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []model.Tag{}
+	for rows.Next() {
+		var tag model.Tag
+		if err := rows.Scan(&tag.Name, &tag.PostCount); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
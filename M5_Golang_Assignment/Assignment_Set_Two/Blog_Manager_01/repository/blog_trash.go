@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"blogmanager/model"
+	"database/sql"
+	"time"
+)
+
+// GetTrash returns the trashed blogs of user, or every trashed blog for an
+// admin, most recently deleted first.
+func (repo *BlogRepository) GetTrash(user *model.User) ([]model.Blog, error) {
+	query := "SELECT " + blogColumns + " FROM blogs WHERE deleted_at IS NOT NULL"
+	var args []any
+	if !user.IsAdmin {
+		query += " AND author_id = ?"
+		args = append(args, user.ID)
+	}
+
+	rows, err := repo.Dialect.query(repo.DB, query+" ORDER BY deleted_at DESC, id DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blogs := []model.Blog{}
+	for rows.Next() {
+		blog, err := scanBlog(rows)
+		if err != nil {
+			return nil, err
+		}
+		blogs = append(blogs, *blog)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := repo.loadTags(blogs); err != nil {
+		return nil, err
+	}
+	return blogs, nil
+}
+
+// GetTrashedBlog returns a blog from the trash.
+func (repo *BlogRepository) GetTrashedBlog(id int) (*model.Blog, error) {
+	row := repo.Dialect.queryRow(repo.DB, "SELECT "+blogColumns+" FROM blogs WHERE id = ? AND deleted_at IS NOT NULL", id)
+	blog, err := scanBlog(row)
+	if err != nil {
+		return nil, err
+	}
+
+	blogs := []model.Blog{*blog}
+	if err := repo.loadTags(blogs); err != nil {
+		return nil, err
+	}
+	return &blogs[0], nil
+}
+
+// RestoreBlog takes a blog out of the trash.
+func (repo *BlogRepository) RestoreBlog(id int) error {
+	res, err := repo.Dialect.exec(repo.DB,
+		"UPDATE blogs SET deleted_at = NULL, updated_at = ?, version = version + 1 WHERE id = ? AND deleted_at IS NOT NULL",
+		formatTime(time.Now()), id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// PurgeTrash permanently deletes the blogs trashed at or before before,
+// together with their comments, tags and revisions, and returns how many
+// were deleted.
+func (repo *BlogRepository) PurgeTrash(before time.Time) (int, error) {
+	res, err := repo.Dialect.exec(repo.DB, "DELETE FROM blogs WHERE deleted_at IS NOT NULL AND deleted_at <= ?",
+		formatTime(before))
+	if err != nil {
+		return 0, err
+	}
+
+	purged, err := res.RowsAffected()
+	return int(purged), err
+}
@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"blogmanager/cache"
+	"blogmanager/model"
+	"encoding/json"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Cache key prefixes of single blogs and of blog pages.
+const (
+	blogCacheKey = "blog:"
+	pageCacheKey = "blogs:"
+)
+
+// CachedBlogStore is a BlogStore that keeps the blogs and pages of blogs read
+// from the store it wraps in Cache for TTL. Writes through it drop every
+// entry they may have made stale, so the wrapped store must not be written
+// to behind its back; a write method added to BlogStore needs an override
+// here that does the same.
+type CachedBlogStore struct {
+	BlogStore
+	Cache cache.Cache
+	TTL   time.Duration
+
+	// generation goes up with every write, so that a read that raced with a
+	// write does not leave what it read in the cache
+	generation atomic.Uint64
+}
+
+var _ BlogStore = (*CachedBlogStore)(nil)
+
+func NewCachedBlogStore(store BlogStore, c cache.Cache, ttl time.Duration) *CachedBlogStore {
+	return &CachedBlogStore{BlogStore: store, Cache: c, TTL: ttl}
+}
+
+func (store *CachedBlogStore) GetBlog(id int) (*model.Blog, error) {
+	key := blogCacheKey + strconv.Itoa(id)
+	blog := &model.Blog{}
+	if store.get(key, blog) {
+		return blog, nil
+	}
+
+	generation := store.generation.Load()
+	blog, err := store.BlogStore.GetBlog(id)
+	if err != nil {
+		return nil, err
+	}
+	store.set(key, blog, generation)
+	return blog, nil
+}
+
+// GetAllBlogs caches pages by their options, including who is viewing them,
+// as unpublished blogs are only listed for their author and admins.
+func (store *CachedBlogStore) GetAllBlogs(opts model.BlogListOptions) (*model.BlogPage, error) {
+	key, err := pageKey(opts)
+	if err != nil {
+		return nil, err
+	}
+	page := &model.BlogPage{}
+	if store.get(key, page) {
+		return page, nil
+	}
+
+	generation := store.generation.Load()
+	page, err = store.BlogStore.GetAllBlogs(opts)
+	if err != nil {
+		return nil, err
+	}
+	store.set(key, page, generation)
+	return page, nil
+}
+
+func (store *CachedBlogStore) CreateBlog(blog *model.Blog) (*model.Blog, error) {
+	created, err := store.BlogStore.CreateBlog(blog)
+	if err == nil {
+		store.invalidate(created.ID)
+	}
+	return created, err
+}
+
+// UpdateBlog also drops the cached blog when the update fails, as a version
+// conflict means that copy is out of date.
+func (store *CachedBlogStore) UpdateBlog(blog *model.Blog) (*model.Blog, error) {
+	updated, err := store.BlogStore.UpdateBlog(blog)
+	store.invalidate(blog.ID)
+	return updated, err
+}
+
+func (store *CachedBlogStore) DeleteBlog(id, version int) error {
+	err := store.BlogStore.DeleteBlog(id, version)
+	store.invalidate(id)
+	return err
+}
+
+func (store *CachedBlogStore) RestoreBlog(id int) error {
+	err := store.BlogStore.RestoreBlog(id)
+	store.invalidate(id)
+	return err
+}
+
+// PublishDueBlogs drops every cached blog once it has published any, as it
+// does not say which.
+func (store *CachedBlogStore) PublishDueBlogs(now time.Time) (int, error) {
+	published, err := store.BlogStore.PublishDueBlogs(now)
+	if published > 0 {
+		store.generation.Add(1)
+		store.Cache.DeletePrefix(blogCacheKey)
+		store.Cache.DeletePrefix(pageCacheKey)
+	}
+	return published, err
+}
+
+func (store *CachedBlogStore) get(key string, value any) bool {
+	data, ok := store.Cache.Get(key)
+	return ok && json.Unmarshal(data, value) == nil
+}
+
+// set caches value unless a write started after generation was read. A write
+// that runs while set does is caught by the check that follows.
+func (store *CachedBlogStore) set(key string, value any, generation uint64) {
+	data, err := json.Marshal(value)
+	if err != nil || store.generation.Load() != generation {
+		return
+	}
+	store.Cache.Set(key, data, store.TTL)
+	if store.generation.Load() != generation {
+		store.Cache.Delete(key)
+	}
+}
+
+// invalidate drops the cached copy of a blog and every cached page, which
+// may list it.
+func (store *CachedBlogStore) invalidate(id int) {
+	store.generation.Add(1)
+	store.Cache.Delete(blogCacheKey + strconv.Itoa(id))
+	store.Cache.DeletePrefix(pageCacheKey)
+}
+
+func pageKey(opts model.BlogListOptions) (string, error) {
+	key := struct {
+		Limit         int
+		After         string
+		Sort          string
+		Author        string
+		Tag           string
+		Status        string
+		From, To      time.Time
+		ViewerID      int
+		ViewerIsAdmin bool
+	}{
+		Limit:  opts.Limit,
+		After:  opts.After,
+		Sort:   opts.Sort,
+		Author: opts.Author,
+		Tag:    opts.Tag,
+		Status: opts.Status,
+		From:   opts.From,
+		To:     opts.To,
+	}
+	if opts.Viewer != nil {
+		key.ViewerID, key.ViewerIsAdmin = opts.Viewer.ID, opts.Viewer.IsAdmin
+	}
+	data, err := json.Marshal(key)
+	if err != nil {
+		return "", err
+	}
+	return pageCacheKey + string(data), nil
+}
@@ -0,0 +1,115 @@
+package repository_test
+
+import (
+	"blogmanager/cache"
+	"blogmanager/model"
+	"blogmanager/repository"
+	"testing"
+	"time"
+)
+
+// racingStore runs during, once, between reading from the store it wraps
+// and returning what it read, as if a write had raced with the read.
+type racingStore struct {
+	repository.BlogStore
+	during func()
+}
+
+func (store *racingStore) race() {
+	if during := store.during; during != nil {
+		store.during = nil
+		during()
+	}
+}
+
+func (store *racingStore) GetBlog(id int) (*model.Blog, error) {
+	blog, err := store.BlogStore.GetBlog(id)
+	store.race()
+	return blog, err
+}
+
+func (store *racingStore) GetAllBlogs(opts model.BlogListOptions) (*model.BlogPage, error) {
+	page, err := store.BlogStore.GetAllBlogs(opts)
+	store.race()
+	return page, err
+}
+
+func TestCachedBlogStoreServesReadsFromCache(t *testing.T) {
+	lru := cache.NewLRU(100)
+	store := repository.NewCachedBlogStore(repository.NewMemoryBlogStore(), lru, time.Minute)
+	author := &model.User{ID: 1, Username: "alice"}
+	blog := createTestBlog(t, store, author, model.Blog{Title: "Cached"})
+	opts := model.BlogListOptions{Limit: 10, Sort: model.SortNewest}
+
+	for range 3 {
+		if _, err := store.GetBlog(blog.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.GetAllBlogs(opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stats := lru.Stats(); stats.Hits != 4 || stats.Misses != 2 {
+		t.Errorf("Stats = %+v, want 4 hits and 2 misses", stats)
+	}
+
+	// A write drops the blog and every page
+	blog.Title = "Updated"
+	if _, err := store.UpdateBlog(blog); err != nil {
+		t.Fatal(err)
+	}
+	got, err := store.GetBlog(blog.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := store.GetAllBlogs(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "Updated" || len(page.Data) != 1 || page.Data[0].Title != "Updated" {
+		t.Errorf("read %q and %v after the update", got.Title, page.Data)
+	}
+}
+
+// TestCachedBlogStoreSkipsReadsRacingWrites checks that a read that started
+// before a write does not cache what it read, which the write made stale.
+func TestCachedBlogStoreSkipsReadsRacingWrites(t *testing.T) {
+	racing := &racingStore{BlogStore: repository.NewMemoryBlogStore()}
+	store := repository.NewCachedBlogStore(racing, cache.NewLRU(100), time.Minute)
+	author := &model.User{ID: 1, Username: "alice"}
+	blog := createTestBlog(t, store, author, model.Blog{Title: "Before"})
+	opts := model.BlogListOptions{Limit: 10, Sort: model.SortNewest}
+
+	update := func(title string) func() {
+		return func() {
+			current, err := racing.BlogStore.GetBlog(blog.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			current.Title = title
+			if _, err := store.UpdateBlog(current); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	racing.during = update("After")
+	if got, err := store.GetBlog(blog.ID); err != nil || got.Title != "Before" {
+		t.Fatalf("racing read = %v, %v", got, err)
+	}
+	if got, err := store.GetBlog(blog.ID); err != nil || got.Title != "After" {
+		t.Errorf("read after the race = %v, %v, want the updated blog", got, err)
+	}
+
+	racing.during = update("Again")
+	if _, err := store.GetAllBlogs(opts); err != nil {
+		t.Fatal(err)
+	}
+	page, err := store.GetAllBlogs(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Data) != 1 || page.Data[0].Title != "Again" {
+		t.Errorf("page read after the race = %v, want the updated blog", page.Data)
+	}
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"blogmanager/model"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type CommentRepository struct {
+	DB      *sql.DB
+	Dialect Dialect
+}
+
+func NewCommentRepository(db *sql.DB, dialect Dialect) *CommentRepository {
+	return &CommentRepository{DB: db, Dialect: dialect}
+}
+
+const commentColumns = "id, blog_id, parent_id, author, author_id, content, created_at, updated_at"
+
+func scanComment(row rowScanner) (*model.Comment, error) {
+	comment := &model.Comment{}
+	var parentID sql.NullInt64
+	var createdAt, updatedAt string
+	err := row.Scan(&comment.ID, &comment.BlogID, &parentID, &comment.Author, &comment.AuthorID,
+		&comment.Content, &createdAt, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if comment.CreatedAt, err = time.Parse(time.RFC3339, createdAt); err != nil {
+		return nil, fmt.Errorf("comment %d has invalid created_at: %v", comment.ID, err)
+	}
+	if comment.UpdatedAt, err = time.Parse(time.RFC3339, updatedAt); err != nil {
+		return nil, fmt.Errorf("comment %d has invalid updated_at: %v", comment.ID, err)
+	}
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		comment.ParentID = &id
+	}
+	return comment, nil
+}
+
+func (repo *CommentRepository) CreateComment(comment *model.Comment) (*model.Comment, error) {
+	now := time.Now().UTC().Truncate(time.Second)
+	id, err := repo.Dialect.insert(repo.DB, `INSERT INTO comments (blog_id, parent_id, author, author_id, content, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		comment.BlogID, comment.ParentID, comment.Author, comment.AuthorID, comment.Content, formatTime(now), formatTime(now))
+	if err != nil {
+		return nil, err
+	}
+
+	comment.ID = int(id)
+	comment.CreatedAt = now
+	comment.UpdatedAt = now
+	return comment, nil
+}
+
+func (repo *CommentRepository) GetComment(id int) (*model.Comment, error) {
+	row := repo.Dialect.queryRow(repo.DB, "SELECT "+commentColumns+" FROM comments WHERE id = ?", id)
+	return scanComment(row)
+}
+
+// GetCommentsByBlog returns every comment and reply on a blog, oldest first.
+func (repo *CommentRepository) GetCommentsByBlog(blogID int) ([]model.Comment, error) {
+	rows, err := repo.Dialect.query(repo.DB, "SELECT "+commentColumns+" FROM comments WHERE blog_id = ? ORDER BY id", blogID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var comments []model.Comment
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, *comment)
+	}
+	return comments, rows.Err()
+}
+
+func (repo *CommentRepository) UpdateComment(comment *model.Comment) (*model.Comment, error) {
+	comment.UpdatedAt = time.Now().UTC().Truncate(time.Second)
+	_, err := repo.Dialect.exec(repo.DB, "UPDATE comments SET content = ?, updated_at = ? WHERE id = ?",
+		comment.Content, formatTime(comment.UpdatedAt), comment.ID)
+	if err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+// DeleteComment removes a comment; its replies are removed by the
+// ON DELETE CASCADE on parent_id.
+func (repo *CommentRepository) DeleteComment(id int) error {
+	_, err := repo.Dialect.exec(repo.DB, "DELETE FROM comments WHERE id = ?", id)
+	return err
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"blogmanager/model"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+var ErrInvalidCursor = errors.New("invalid pagination cursor")
+
+// listCursor marks the last blog of a page by the columns it is sorted on:
+// created_at and id for the newest and oldest orders, title and id for the
+// title order. It records the sort order it was issued for so that it cannot
+// be replayed against a different ordering.
+type listCursor struct {
+	Sort      string `json:"s"`
+	ID        int    `json:"id"`
+	CreatedAt string `json:"c,omitempty"`
+	Title     string `json:"t,omitempty"`
+}
+
+// nextCursor returns the cursor of the page that follows last.
+func nextCursor(sort string, last *model.Blog) string {
+	cursor := listCursor{Sort: sort, ID: last.ID}
+	if sort == model.SortTitle {
+		cursor.Title = last.Title
+	} else {
+		cursor.CreatedAt = formatTime(last.CreatedAt)
+	}
+	return encodeCursor(cursor)
+}
+
+func encodeCursor(cursor listCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(encoded, sort string) (listCursor, error) {
+	var cursor listCursor
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return cursor, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.Sort != sort {
+		return cursor, ErrInvalidCursor
+	}
+	// Cursors issued before the newest and oldest orders went by created_at
+	// only hold an id
+	if sort != model.SortTitle && cursor.CreatedAt == "" {
+		return cursor, ErrInvalidCursor
+	}
+	return cursor, nil
+}
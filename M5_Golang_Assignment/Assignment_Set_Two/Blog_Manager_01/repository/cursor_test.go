@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"blogmanager/model"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	blog := &model.Blog{ID: 7, Title: "Hello, world", CreatedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	tests := []struct {
+		sort string
+		want listCursor
+	}{
+		{model.SortNewest, listCursor{Sort: model.SortNewest, ID: 7, CreatedAt: "2024-05-01T12:00:00Z"}},
+		{model.SortOldest, listCursor{Sort: model.SortOldest, ID: 7, CreatedAt: "2024-05-01T12:00:00Z"}},
+		{model.SortTitle, listCursor{Sort: model.SortTitle, ID: 7, Title: "Hello, world"}},
+	}
+	for _, tt := range tests {
+		got, err := decodeCursor(nextCursor(tt.sort, blog), tt.sort)
+		if err != nil {
+			t.Errorf("decodeCursor(nextCursor(%s)) failed: %v", tt.sort, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("decodeCursor(nextCursor(%s)) = %+v, want %+v", tt.sort, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeCursorRejects(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	tests := []struct {
+		name    string
+		encoded string
+		sort    string
+	}{
+		{"not base64", "!!!", model.SortNewest},
+		{"not json", encode("nope"), model.SortNewest},
+		{"other sort", encode(`{"s":"title","id":1,"t":"x"}`), model.SortNewest},
+		{"id only", encode(`{"s":"newest","id":1}`), model.SortNewest},
+		{"id only oldest", encode(`{"s":"oldest","id":1}`), model.SortOldest},
+	}
+	for _, tt := range tests {
+		if _, err := decodeCursor(tt.encoded, tt.sort); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: decodeCursor = %v, want ErrInvalidCursor", tt.name, err)
+		}
+	}
+}
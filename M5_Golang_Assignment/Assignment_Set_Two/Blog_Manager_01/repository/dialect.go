@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+// Dialect is the SQL flavour of the database behind a repository. Queries are
+// written with ? placeholders and rebound to $1, $2... for PostgreSQL.
+type Dialect string
+
+const (
+	SQLite   Dialect = "sqlite3"
+	Postgres Dialect = "postgres"
+)
+
+// DialectFor returns the dialect of a database/sql driver name.
+func DialectFor(driver string) (Dialect, error) {
+	switch Dialect(driver) {
+	case SQLite, Postgres:
+		return Dialect(driver), nil
+	default:
+		return "", fmt.Errorf("unsupported database driver %q", driver)
+	}
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// Rebind rewrites the ? placeholders of query for the dialect. Queries must
+// not contain a literal ?.
+func (d Dialect) Rebind(query string) string {
+	if d != Postgres || !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (d Dialect) exec(q queryer, query string, args ...any) (sql.Result, error) {
+	return q.Exec(d.Rebind(query), args...)
+}
+
+func (d Dialect) query(q queryer, query string, args ...any) (*sql.Rows, error) {
+	return q.Query(d.Rebind(query), args...)
+}
+
+func (d Dialect) queryRow(q queryer, query string, args ...any) *sql.Row {
+	return q.QueryRow(d.Rebind(query), args...)
+}
+
+// insert runs an INSERT into a table with an id primary key and returns the
+// id of the new row. PostgreSQL drivers do not support LastInsertId.
+func (d Dialect) insert(q queryer, query string, args ...any) (int64, error) {
+	if d == Postgres {
+		var id int64
+		err := q.QueryRow(d.Rebind(query+" RETURNING id"), args...).Scan(&id)
+		return id, err
+	}
+
+	res, err := q.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// isUniqueViolation reports whether err comes from a UNIQUE constraint.
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505"
+	}
+	return false
+}
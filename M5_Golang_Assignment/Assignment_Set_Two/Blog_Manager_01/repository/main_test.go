@@ -0,0 +1,19 @@
+package repository_test
+
+import (
+	"blogmanager/model"
+	"blogmanager/repository"
+	"database/sql"
+	"testing"
+)
+
+// createTestUser stores a user to own test blogs.
+func createTestUser(t *testing.T, database *sql.DB, dialect repository.Dialect, username string) *model.User {
+	t.Helper()
+	user, err := repository.NewUserRepository(database, dialect).
+		CreateUser(&model.User{Username: username, PasswordHash: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
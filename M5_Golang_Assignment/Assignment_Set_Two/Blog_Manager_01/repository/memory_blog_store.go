@@ -0,0 +1,409 @@
+package repository
+
+import (
+	"blogmanager/model"
+	"database/sql"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryBlogStore is a BlogStore that keeps everything in memory, meant for
+// tests. It follows the SQL repository closely, except that search results
+// are ranked by a simple term count.
+type MemoryBlogStore struct {
+	mu        sync.Mutex
+	nextID    int
+	blogs     map[int]*model.Blog
+	revisions map[int][]model.BlogRevision
+}
+
+func NewMemoryBlogStore() *MemoryBlogStore {
+	return &MemoryBlogStore{
+		nextID:    1,
+		blogs:     make(map[int]*model.Blog),
+		revisions: make(map[int][]model.BlogRevision),
+	}
+}
+
+// copyBlog returns a copy of blog that shares no memory with it. Like the
+// SQL repository it leaves out the rendered HTML unless withHTML is set.
+func copyBlog(blog *model.Blog, withHTML bool) *model.Blog {
+	c := *blog
+	c.Tags = slices.Clone(blog.Tags)
+	if c.Tags == nil {
+		c.Tags = []string{}
+	}
+	if blog.PublishAt != nil {
+		t := *blog.PublishAt
+		c.PublishAt = &t
+	}
+	if blog.DeletedAt != nil {
+		t := *blog.DeletedAt
+		c.DeletedAt = &t
+	}
+	if !withHTML {
+		c.HTML = ""
+	}
+	return &c
+}
+
+// storedTime truncates t the way the SQL repository's RFC 3339 text does.
+func storedTime(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Second)
+}
+
+func (store *MemoryBlogStore) CreateBlog(blog *model.Blog) (*model.Blog, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if blog.CreatedAt.IsZero() {
+		blog.CreatedAt = time.Now()
+	}
+	if blog.UpdatedAt.IsZero() {
+		blog.UpdatedAt = blog.CreatedAt
+	}
+	blog.ID = store.nextID
+	blog.CreatedAt = storedTime(blog.CreatedAt)
+	blog.UpdatedAt = storedTime(blog.UpdatedAt)
+	if blog.PublishAt != nil {
+		publishAt := storedTime(*blog.PublishAt)
+		blog.PublishAt = &publishAt
+	}
+	blog.Version = 1
+	if blog.Tags == nil {
+		blog.Tags = []string{}
+	}
+	store.nextID++
+
+	store.blogs[blog.ID] = copyBlog(blog, true)
+	return blog, nil
+}
+
+func (store *MemoryBlogStore) GetBlog(id int) (*model.Blog, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	blog, ok := store.blogs[id]
+	if !ok || blog.DeletedAt != nil {
+		return nil, sql.ErrNoRows
+	}
+	return copyBlog(blog, false), nil
+}
+
+// visibleTo mirrors visibilityCondition.
+func visibleTo(blog *model.Blog, viewer *model.User) bool {
+	switch {
+	case blog.DeletedAt != nil:
+		return false
+	case viewer == nil:
+		return blog.Status == model.StatusPublished
+	case viewer.IsAdmin:
+		return true
+	default:
+		return blog.Status == model.StatusPublished || blog.AuthorID == viewer.ID
+	}
+}
+
+func (store *MemoryBlogStore) GetAllBlogs(opts model.BlogListOptions) (*model.BlogPage, error) {
+	var cursor listCursor
+	if opts.After != "" {
+		var err error
+		if cursor, err = decodeCursor(opts.After, opts.Sort); err != nil {
+			return nil, err
+		}
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	var matches []*model.Blog
+	for _, blog := range store.blogs {
+		switch {
+		case !visibleTo(blog, opts.Viewer),
+			opts.Status != "" && blog.Status != opts.Status,
+			opts.Author != "" && blog.Author != opts.Author,
+			opts.Tag != "" && !slices.Contains(blog.Tags, opts.Tag),
+			!opts.From.IsZero() && blog.CreatedAt.Before(storedTime(opts.From)),
+			!opts.To.IsZero() && blog.CreatedAt.After(storedTime(opts.To)):
+			continue
+		}
+		matches = append(matches, blog)
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		switch opts.Sort {
+		case model.SortOldest:
+			return a.CreatedAt.Before(b.CreatedAt) || (a.CreatedAt.Equal(b.CreatedAt) && a.ID < b.ID)
+		case model.SortTitle:
+			return a.Title < b.Title || (a.Title == b.Title && a.ID < b.ID)
+		default:
+			return a.CreatedAt.After(b.CreatedAt) || (a.CreatedAt.Equal(b.CreatedAt) && a.ID > b.ID)
+		}
+	})
+
+	page := &model.BlogPage{Data: []model.Blog{}, Total: len(matches)}
+	for _, blog := range matches {
+		if opts.After != "" {
+			var after bool
+			createdAt := formatTime(blog.CreatedAt)
+			switch opts.Sort {
+			case model.SortOldest:
+				after = createdAt > cursor.CreatedAt || (createdAt == cursor.CreatedAt && blog.ID > cursor.ID)
+			case model.SortTitle:
+				after = blog.Title > cursor.Title || (blog.Title == cursor.Title && blog.ID > cursor.ID)
+			default:
+				after = createdAt < cursor.CreatedAt || (createdAt == cursor.CreatedAt && blog.ID < cursor.ID)
+			}
+			if !after {
+				continue
+			}
+		}
+
+		if len(page.Data) == opts.Limit {
+			page.NextCursor = nextCursor(opts.Sort, &page.Data[len(page.Data)-1])
+			break
+		}
+		page.Data = append(page.Data, *copyBlog(blog, false))
+	}
+	return page, nil
+}
+
+func (store *MemoryBlogStore) UpdateBlog(blog *model.Blog) (*model.Blog, error) {
+	store.mu.Lock()
+	stored, ok := store.blogs[blog.ID]
+	if !ok || stored.DeletedAt != nil {
+		store.mu.Unlock()
+		return nil, sql.ErrNoRows
+	}
+	if blog.Version != 0 && blog.Version != stored.Version {
+		store.mu.Unlock()
+		return nil, ErrVersionConflict
+	}
+
+	now := storedTime(time.Now())
+	tags := append([]string{}, stored.Tags...)
+	slices.Sort(tags)
+	store.revisions[blog.ID] = append(store.revisions[blog.ID], model.BlogRevision{
+		BlogID:    blog.ID,
+		Revision:  len(store.revisions[blog.ID]) + 1,
+		Title:     stored.Title,
+		Content:   stored.Content,
+		Status:    stored.Status,
+		Tags:      tags,
+		CreatedAt: now.Format(time.RFC3339),
+	})
+
+	updated := copyBlog(blog, true)
+	updated.AuthorID = stored.AuthorID
+	updated.CreatedAt = stored.CreatedAt
+	updated.UpdatedAt = now
+	updated.Version = stored.Version + 1
+	if updated.PublishAt != nil {
+		*updated.PublishAt = storedTime(*updated.PublishAt)
+	}
+	if blog.Tags == nil {
+		updated.Tags = stored.Tags
+	}
+	store.blogs[blog.ID] = updated
+	store.mu.Unlock()
+
+	return store.GetBlog(blog.ID)
+}
+
+func (store *MemoryBlogStore) DeleteBlog(id, version int) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	blog, ok := store.blogs[id]
+	switch {
+	case version != 0 && (!ok || blog.DeletedAt != nil || blog.Version != version):
+		return ErrVersionConflict
+	case !ok || blog.DeletedAt != nil:
+		return sql.ErrNoRows
+	}
+	now := storedTime(time.Now())
+	blog.DeletedAt = &now
+	blog.UpdatedAt = now
+	blog.Version++
+	return nil
+}
+
+func (store *MemoryBlogStore) GetRenderedHTML(id int) (string, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	blog, ok := store.blogs[id]
+	if !ok {
+		return "", sql.ErrNoRows
+	}
+	return blog.HTML, nil
+}
+
+func (store *MemoryBlogStore) SaveRenderedHTML(id int, rendered string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if blog, ok := store.blogs[id]; ok {
+		blog.HTML = rendered
+	}
+	return nil
+}
+
+func (store *MemoryBlogStore) PublishDueBlogs(now time.Time) (int, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	published := 0
+	for _, blog := range store.blogs {
+		if blog.Status == model.StatusScheduled && blog.PublishAt != nil && !blog.PublishAt.After(storedTime(now)) &&
+			blog.DeletedAt == nil {
+			blog.Status = model.StatusPublished
+			blog.UpdatedAt = storedTime(now)
+			blog.Version++
+			published++
+		}
+	}
+	return published, nil
+}
+
+func (store *MemoryBlogStore) LastChanged(author string) (time.Time, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	var last time.Time
+	for _, blog := range store.blogs {
+		if (author == "" || blog.Author == author) && blog.UpdatedAt.After(last) {
+			last = blog.UpdatedAt
+		}
+	}
+	return last, nil
+}
+
+// Search matches terms in Go, like the SQL repository without a full-text
+// index.
+func (store *MemoryBlogStore) Search(terms []SearchTerm, limit int, viewer *model.User) ([]model.BlogSearchResult, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	results := []model.BlogSearchResult{}
+	for _, blog := range store.blogs {
+		if !visibleTo(blog, viewer) {
+			continue
+		}
+		if result, ok := matchBlog(blog, terms); ok {
+			results = append(results, result)
+		}
+	}
+	return rankResults(results, limit), nil
+}
+
+func (store *MemoryBlogStore) GetTags() ([]model.Tag, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	counts := make(map[string]int)
+	for _, blog := range store.blogs {
+		if blog.Status != model.StatusPublished || blog.DeletedAt != nil {
+			continue
+		}
+		for _, tag := range blog.Tags {
+			counts[tag]++
+		}
+	}
+
+	tags := []model.Tag{}
+	for name, count := range counts {
+		tags = append(tags, model.Tag{Name: name, PostCount: count})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].PostCount != tags[j].PostCount {
+			return tags[i].PostCount > tags[j].PostCount
+		}
+		return tags[i].Name < tags[j].Name
+	})
+	return tags, nil
+}
+
+func (store *MemoryBlogStore) GetRevisions(blogID int) ([]model.BlogRevision, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	revisions := slices.Clone(store.revisions[blogID])
+	slices.Reverse(revisions)
+	if revisions == nil {
+		revisions = []model.BlogRevision{}
+	}
+	return revisions, nil
+}
+
+func (store *MemoryBlogStore) GetRevision(blogID, revision int) (*model.BlogRevision, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	revisions := store.revisions[blogID]
+	if revision < 1 || revision > len(revisions) {
+		return nil, sql.ErrNoRows
+	}
+	rev := revisions[revision-1]
+	return &rev, nil
+}
+
+func (store *MemoryBlogStore) GetTrash(user *model.User) ([]model.Blog, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	blogs := []model.Blog{}
+	for _, blog := range store.blogs {
+		if blog.DeletedAt != nil && (user.IsAdmin || blog.AuthorID == user.ID) {
+			blogs = append(blogs, *copyBlog(blog, false))
+		}
+	}
+	sort.Slice(blogs, func(i, j int) bool {
+		a, b := blogs[i], blogs[j]
+		return a.DeletedAt.After(*b.DeletedAt) || (a.DeletedAt.Equal(*b.DeletedAt) && a.ID > b.ID)
+	})
+	return blogs, nil
+}
+
+func (store *MemoryBlogStore) GetTrashedBlog(id int) (*model.Blog, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	blog, ok := store.blogs[id]
+	if !ok || blog.DeletedAt == nil {
+		return nil, sql.ErrNoRows
+	}
+	return copyBlog(blog, false), nil
+}
+
+func (store *MemoryBlogStore) RestoreBlog(id int) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	blog, ok := store.blogs[id]
+	if !ok || blog.DeletedAt == nil {
+		return sql.ErrNoRows
+	}
+	blog.DeletedAt = nil
+	blog.UpdatedAt = storedTime(time.Now())
+	blog.Version++
+	return nil
+}
+
+func (store *MemoryBlogStore) PurgeTrash(before time.Time) (int, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	purged := 0
+	for id, blog := range store.blogs {
+		if blog.DeletedAt != nil && !blog.DeletedAt.After(storedTime(before)) {
+			delete(store.blogs, id)
+			delete(store.revisions, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"blogmanager/model"
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// The databases wrap matches in these control characters instead of <mark>
+// tags, so the text can be escaped before the tags are put in. Text holding
+// them itself can at worst gain stray <mark> tags.
+const (
+	highlightStart = "\x02"
+	highlightStop  = "\x03"
+)
+
+// snippetWords is how many words a snippet holds.
+const snippetWords = 16
+
+// markHighlights escapes text highlighted by the database for HTML and turns
+// its highlight markers into <mark></mark>.
+func markHighlights(text string) string {
+	text = html.EscapeString(text)
+	text = strings.ReplaceAll(text, highlightStart, "<mark>")
+	return strings.ReplaceAll(text, highlightStop, "</mark>")
+}
+
+// wordSpan is a word of a text and where it is, in bytes.
+type wordSpan struct {
+	word       string
+	start, end int
+}
+
+// textWords splits text into lowercased words of letters and digits, the way
+// ParseSearchQuery does.
+func textWords(text string) []wordSpan {
+	var spans []wordSpan
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWord && start < 0 {
+			start = i
+		} else if !isWord && start >= 0 {
+			spans = append(spans, wordSpan{strings.ToLower(text[start:i]), start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, wordSpan{strings.ToLower(text[start:]), start, len(text)})
+	}
+	return spans
+}
+
+// markTerm flags the words of every occurrence of term in words and reports
+// whether there was any. The words of term must appear consecutively, the
+// last one as a prefix if term.Prefix is set.
+func markTerm(words []wordSpan, marked []bool, term SearchTerm) bool {
+	found := false
+	n := len(term.Words)
+	for i := 0; i+n <= len(words); i++ {
+		match := true
+		for j, want := range term.Words {
+			want = strings.ToLower(want)
+			got := words[i+j].word
+			if j == n-1 && term.Prefix {
+				match = strings.HasPrefix(got, want)
+			} else {
+				match = got == want
+			}
+			if !match {
+				break
+			}
+		}
+		if match {
+			found = true
+			for j := i; j < i+n; j++ {
+				marked[j] = true
+			}
+		}
+	}
+	return found
+}
+
+// highlightWords escapes words[from:to] of text for HTML, wrapping the marked
+// words in <mark></mark>.
+func highlightWords(text string, words []wordSpan, marked []bool, from, to int) string {
+	if from >= to {
+		return ""
+	}
+	var b strings.Builder
+	pos := words[from].start
+	for i := from; i < to; i++ {
+		b.WriteString(html.EscapeString(text[pos:words[i].start]))
+		word := html.EscapeString(text[words[i].start:words[i].end])
+		if marked[i] {
+			word = "<mark>" + word + "</mark>"
+		}
+		b.WriteString(word)
+		pos = words[i].end
+	}
+	return b.String()
+}
+
+// matchBlog searches a blog in Go, for stores without a full-text index. It
+// reports whether the blog contains every term and, if so, returns it as a
+// search result. A title match ranks ten times higher than a content match;
+// the snippet is the content around the first match.
+func matchBlog(blog *model.Blog, terms []SearchTerm) (model.BlogSearchResult, bool) {
+	titleWords, contentWords := textWords(blog.Title), textWords(blog.Content)
+	titleMarked, contentMarked := make([]bool, len(titleWords)), make([]bool, len(contentWords))
+	score := 0
+	for _, term := range terms {
+		inTitle := markTerm(titleWords, titleMarked, term)
+		inContent := markTerm(contentWords, contentMarked, term)
+		if !inTitle && !inContent {
+			return model.BlogSearchResult{}, false
+		}
+		if inTitle {
+			score += 10
+		}
+		if inContent {
+			score++
+		}
+	}
+
+	// Leave a few words of context before the first match
+	from := 0
+	for i, marked := range contentMarked {
+		if marked {
+			from = max(min(i-snippetWords/4, len(contentWords)-snippetWords), 0)
+			break
+		}
+	}
+	to := min(from+snippetWords, len(contentWords))
+	snippet := highlightWords(blog.Content, contentWords, contentMarked, from, to)
+	if from > 0 {
+		snippet = "…" + snippet
+	}
+	if to < len(contentWords) {
+		snippet += "…"
+	}
+
+	title := html.EscapeString(blog.Title)
+	if len(titleWords) > 0 {
+		first, last := titleWords[0], titleWords[len(titleWords)-1]
+		title = html.EscapeString(blog.Title[:first.start]) +
+			highlightWords(blog.Title, titleWords, titleMarked, 0, len(titleWords)) +
+			html.EscapeString(blog.Title[last.end:])
+	}
+
+	return model.BlogSearchResult{
+		ID:             blog.ID,
+		Title:          blog.Title,
+		Author:         blog.Author,
+		AuthorID:       blog.AuthorID,
+		CreatedAt:      blog.CreatedAt,
+		UpdatedAt:      blog.UpdatedAt,
+		TitleHighlight: title,
+		Snippet:        snippet,
+		Rank:           -float64(score),
+	}, true
+}
+
+// rankResults sorts the results of matchBlog best first, newest first among
+// equals, and keeps the first limit.
+func rankResults(results []model.BlogSearchResult, limit int) []model.BlogSearchResult {
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank < results[j].Rank
+		}
+		return results[i].ID > results[j].ID
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// likePatterns returns a LIKE pattern for every term, which any title or
+// content containing the term matches. It is a cheap filter ahead of
+// matchBlog, which has the final word; as SQLite only folds the case of ASCII
+// letters, other letters must match in case.
+func likePatterns(terms []SearchTerm) []string {
+	patterns := make([]string, len(terms))
+	for i, term := range terms {
+		// Words only hold letters and digits, so they need no escaping
+		patterns[i] = "%" + strings.Join(term.Words, "%") + "%"
+	}
+	return patterns
+}
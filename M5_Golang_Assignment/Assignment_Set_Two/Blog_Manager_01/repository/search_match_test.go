@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"blogmanager/model"
+	"strings"
+	"testing"
+)
+
+func TestMarkHighlights(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"plain", "plain"},
+		{"\x02go\x03 blogs", "<mark>go</mark> blogs"},
+		{"<script>\x02alert\x03(1)</script>", "&lt;script&gt;<mark>alert</mark>(1)&lt;/script&gt;"},
+		{`a "quoted" & 'single'`, "a &#34;quoted&#34; &amp; &#39;single&#39;"},
+	}
+	for _, tt := range tests {
+		if got := markHighlights(tt.text); got != tt.want {
+			t.Errorf("markHighlights(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestMatchBlog(t *testing.T) {
+	long := "one two three four five six seven eight nine ten eleven twelve thirteen fourteen fifteen sixteen seventeen eighteen target nineteen twenty a b c d e f g h i j k"
+	tests := []struct {
+		name        string
+		title       string
+		content     string
+		query       string
+		wantMatch   bool
+		wantTitle   string
+		wantSnippet string
+		wantRank    float64
+	}{
+		{
+			name: "title and content", title: "Go blogs", content: "Writing Go code", query: "go",
+			wantMatch: true, wantTitle: "<mark>Go</mark> blogs", wantSnippet: "Writing <mark>Go</mark> code", wantRank: -11,
+		},
+		{
+			name: "every term needed", title: "Go blogs", content: "Writing Go code", query: "go rust",
+		},
+		{
+			name: "phrase in order", title: "x", content: "world hello", query: `"hello world"`,
+		},
+		{
+			name: "prefix", title: "x", content: "Programming in Go", query: "prog*",
+			wantMatch: true, wantTitle: "x", wantSnippet: "<mark>Programming</mark> in Go", wantRank: -1,
+		},
+		{
+			name: "html is escaped", title: `<img src=x onerror="alert(1)">`, content: "<script>alert('xss')</script>", query: "alert",
+			wantMatch:   true,
+			wantTitle:   "&lt;img src=x onerror=&#34;<mark>alert</mark>(1)&#34;&gt;",
+			wantSnippet: "script&gt;<mark>alert</mark>(&#39;xss&#39;)&lt;/script",
+			wantRank:    -11,
+		},
+		{
+			name: "snippet around the match", title: "x", content: long, query: "target",
+			wantMatch:   true,
+			wantTitle:   "x",
+			wantSnippet: "…fifteen sixteen seventeen eighteen <mark>target</mark> nineteen twenty a b c d e f g h i…",
+			wantRank:    -1,
+		},
+		{
+			name: "snippet of the start", title: "Target", content: long, query: "target",
+			wantMatch:   true,
+			wantTitle:   "<mark>Target</mark>",
+			wantSnippet: "…fifteen sixteen seventeen eighteen <mark>target</mark> nineteen twenty a b c d e f g h i…",
+			wantRank:    -11,
+		},
+		{
+			name: "snippet at the end", title: "x", content: long, query: "k",
+			wantMatch:   true,
+			wantTitle:   "x",
+			wantSnippet: "…seventeen eighteen target nineteen twenty a b c d e f g h i j <mark>k</mark>",
+			wantRank:    -1,
+		},
+		{
+			name: "missing term", title: "Target", content: long, query: "x target",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, ok := matchBlog(&model.Blog{ID: 1, Title: tt.title, Content: tt.content}, ParseSearchQuery(tt.query))
+			if ok != tt.wantMatch {
+				t.Fatalf("matchBlog(%q) matched = %v, want %v", tt.query, ok, tt.wantMatch)
+			}
+			if !ok {
+				return
+			}
+			if result.TitleHighlight != tt.wantTitle {
+				t.Errorf("TitleHighlight = %q, want %q", result.TitleHighlight, tt.wantTitle)
+			}
+			if result.Snippet != tt.wantSnippet {
+				t.Errorf("Snippet = %q, want %q", result.Snippet, tt.wantSnippet)
+			}
+			if result.Rank != tt.wantRank {
+				t.Errorf("Rank = %v, want %v", result.Rank, tt.wantRank)
+			}
+			if strings.Contains(result.TitleHighlight+result.Snippet, "<script") {
+				t.Error("result holds unescaped HTML")
+			}
+		})
+	}
+}
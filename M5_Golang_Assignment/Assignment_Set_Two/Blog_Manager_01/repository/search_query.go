@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SearchTerm is a word or phrase that every search result must contain. With
+// Prefix set, its last word only needs to be the start of a word.
+type SearchTerm struct {
+	Words  []string
+	Prefix bool
+}
+
+// ParseSearchQuery turns free-form user input into search terms. Quoted text
+// becomes a phrase, a trailing * turns a word (or phrase) into a prefix and
+// anything but letters and digits separates words.
+func ParseSearchQuery(input string) []SearchTerm {
+	var terms []SearchTerm
+	rest := input
+	for {
+		rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+		if rest == "" {
+			break
+		}
+
+		var raw string
+		if rest[0] == '"' {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				raw, rest = rest[1:], ""
+			} else {
+				raw, rest = rest[1:end+1], rest[end+2:]
+			}
+			if strings.HasPrefix(rest, "*") {
+				raw += "*"
+				rest = rest[1:]
+			}
+		} else {
+			end := strings.IndexFunc(rest, func(r rune) bool { return unicode.IsSpace(r) || r == '"' })
+			if end < 0 {
+				end = len(rest)
+			}
+			raw, rest = rest[:end], rest[end:]
+		}
+
+		words := strings.FieldsFunc(raw, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		if len(words) > 0 {
+			terms = append(terms, SearchTerm{Words: words, Prefix: strings.HasSuffix(raw, "*")})
+		}
+	}
+	return terms
+}
+
+// matchExpression builds an FTS5 MATCH expression requiring every term, each
+// quoted as a phrase so that no FTS5 syntax can slip through.
+func matchExpression(terms []SearchTerm) string {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		parts[i] = `"` + strings.Join(term.Words, " ") + `"`
+		if term.Prefix {
+			parts[i] += "*"
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// tsQuery builds a PostgreSQL tsquery requiring every term. Words only hold
+// letters and digits, so they need no quoting.
+func tsQuery(terms []SearchTerm) string {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		parts[i] = strings.Join(term.Words, " <-> ")
+		if term.Prefix {
+			parts[i] += ":*"
+		}
+	}
+	return strings.Join(parts, " & ")
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []SearchTerm
+	}{
+		{"empty", "", nil},
+		{"only punctuation", `  ?! "" *`, nil},
+		{"words", "go  blogs", []SearchTerm{{Words: []string{"go"}}, {Words: []string{"blogs"}}}},
+		{"prefix", "prog*", []SearchTerm{{Words: []string{"prog"}, Prefix: true}}},
+		{"phrase", `"hello world"`, []SearchTerm{{Words: []string{"hello", "world"}}}},
+		{"prefix phrase", `"hello wor"*`, []SearchTerm{{Words: []string{"hello", "wor"}, Prefix: true}}},
+		{"unclosed quote", `go "hello world`, []SearchTerm{{Words: []string{"go"}}, {Words: []string{"hello", "world"}}}},
+		{"punctuation splits words", "c++/go-lang", []SearchTerm{{Words: []string{"c", "go", "lang"}}}},
+		{"fts syntax", `title:x OR NEAR(a b)`, []SearchTerm{
+			{Words: []string{"title", "x"}}, {Words: []string{"OR"}}, {Words: []string{"NEAR", "a"}}, {Words: []string{"b"}},
+		}},
+		{"unicode", "Überblick café", []SearchTerm{{Words: []string{"Überblick"}}, {Words: []string{"café"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseSearchQuery(tt.input); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseSearchQuery(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryExpressions(t *testing.T) {
+	tests := []struct {
+		input     string
+		wantMatch string
+		wantTS    string
+	}{
+		{"go", `"go"`, "go"},
+		{`"hello world" prog*`, `"hello world" "prog"*`, "hello <-> world & prog:*"},
+		{`title:x "a b"*`, `"title x" "a b"*`, "title <-> x & a <-> b:*"},
+	}
+	for _, tt := range tests {
+		terms := ParseSearchQuery(tt.input)
+		if got := matchExpression(terms); got != tt.wantMatch {
+			t.Errorf("matchExpression(%q) = %s, want %s", tt.input, got, tt.wantMatch)
+		}
+		if got := tsQuery(terms); got != tt.wantTS {
+			t.Errorf("tsQuery(%q) = %s, want %s", tt.input, got, tt.wantTS)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"blogmanager/model"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrUsernameTaken = errors.New("username already taken")
+
+type UserRepository struct {
+	DB      *sql.DB
+	Dialect Dialect
+}
+
+func NewUserRepository(db *sql.DB, dialect Dialect) *UserRepository {
+	return &UserRepository{DB: db, Dialect: dialect}
+}
+
+// CreateUser stores a new user, an admin if user.IsAdmin is set.
+func (repo *UserRepository) CreateUser(user *model.User) (*model.User, error) {
+	user.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	isAdmin := 0
+	if user.IsAdmin {
+		isAdmin = 1
+	}
+	_, err := repo.Dialect.exec(repo.DB, `INSERT INTO users (username, password_hash, is_admin, created_at)
+		VALUES (?, ?, ?, ?)`,
+		user.Username, user.PasswordHash, isAdmin, user.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrUsernameTaken
+		}
+		return nil, err
+	}
+
+	// Read the row back so the caller sees the assigned id
+	return repo.GetUserByUsername(user.Username)
+}
+
+func (repo *UserRepository) GetUserByUsername(username string) (*model.User, error) {
+	row := repo.Dialect.queryRow(repo.DB, "SELECT id, username, password_hash, is_admin, created_at FROM users WHERE username = ?", username)
+	user := &model.User{}
+	err := row.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.IsAdmin, &user.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// MakeAdmin grants admin rights to an existing user.
+func (repo *UserRepository) MakeAdmin(id int) error {
+	res, err := repo.Dialect.exec(repo.DB, "UPDATE users SET is_admin = 1 WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// HasAdmin reports whether any user is an admin.
+func (repo *UserRepository) HasAdmin() (bool, error) {
+	var exists bool
+	err := repo.Dialect.queryRow(repo.DB, "SELECT EXISTS (SELECT 1 FROM users WHERE is_admin = 1)").Scan(&exists)
+	return exists, err
+}
+
+func (repo *UserRepository) UpdatePassword(id int, passwordHash string) error {
+	res, err := repo.Dialect.exec(repo.DB, "UPDATE users SET password_hash = ? WHERE id = ?", passwordHash, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
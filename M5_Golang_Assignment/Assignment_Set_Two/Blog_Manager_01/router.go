@@ -0,0 +1,37 @@
+package main
+
+import (
+	"blogmanager/metrics"
+	"blogmanager/middleware"
+	"blogmanager/settings"
+	"servicekit/health"
+	"servicekit/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newRouter returns a router serving the metrics and health checks. Every
+// route registered on it afterwards is limited by ipLimiter, which the
+// probes are not, so that an orchestrator polling them is never refused.
+func newRouter(cfg *settings.Config, checker *health.Checker, ipLimiter *ratelimit.Limiter) (*gin.Engine, error) {
+	r := gin.New()
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return nil, err
+	}
+
+	// Tag every request with an ID, log it and recover from panics
+	r.Use(middleware.RequestIDMiddleware(), middleware.LoggingMiddleware(), metrics.Registry.Middleware(), middleware.RecoveryMiddleware())
+	if len(cfg.CORSOrigins) > 0 {
+		r.Use(middleware.CORSMiddleware(cfg.CORSOrigins))
+	}
+
+	// Metrics and health checks are served without credentials
+	r.GET("/metrics", metrics.Registry.Handler())
+	r.GET("/healthz", checker.Liveness)
+	r.GET("/readyz", checker.Readiness)
+
+	// Everything below is rate limited, starting with the unauthenticated
+	// registration and feeds
+	r.Use(ipLimiter.Middleware())
+	return r, nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"blogmanager/settings"
+	"net/http"
+	"net/http/httptest"
+	"servicekit/health"
+	"servicekit/ratelimit"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestProbesAreNotRateLimited(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ipLimiter := ratelimit.NewLimiter(ratelimit.Limit{Rate: 0.001, Burst: 1}, nil, time.Minute, ratelimit.ByIP)
+	r, err := newRouter(settings.Default(), health.NewChecker(time.Second), ipLimiter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.GET("/limited", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	get := func(path string) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+	if code := get("/limited"); code != http.StatusNoContent {
+		t.Fatalf("first request: status %d, want %d", code, http.StatusNoContent)
+	}
+	if code := get("/limited"); code != http.StatusTooManyRequests {
+		t.Fatalf("request over the limit: status %d, want %d", code, http.StatusTooManyRequests)
+	}
+	for _, path := range []string{"/healthz", "/readyz", "/metrics"} {
+		for i := 0; i < 5; i++ {
+			if code := get(path); code != http.StatusOK {
+				t.Fatalf("GET %s #%d from a limited client: status %d, want %d", path, i+1, code, http.StatusOK)
+			}
+		}
+	}
+}
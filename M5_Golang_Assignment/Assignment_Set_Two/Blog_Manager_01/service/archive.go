@@ -0,0 +1,388 @@
+package service
+
+import (
+	"archive/zip"
+	"blogmanager/model"
+	"blogmanager/repository"
+	"bytes"
+	"cmp"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Conflict strategies of ImportArchive, for archived blogs whose author
+// already has a different blog with the same title.
+const (
+	ConflictSkip      = "skip"
+	ConflictOverwrite = "overwrite"
+	ConflictRename    = "rename"
+)
+
+var (
+	ErrInvalidArchive  = errors.New("invalid archive")
+	ErrInvalidConflict = errors.New("conflict must be one of skip, overwrite or rename")
+)
+
+const (
+	archiveVersion  = 1
+	manifestFile    = "manifest.json"
+	maxArchivedPost = 10 << 20
+	exportPageSize  = 100
+)
+
+// ArchiveManifest lists the blogs of an archive, in the order they are
+// imported.
+type ArchiveManifest struct {
+	Version    int            `json:"version"`
+	ExportedAt time.Time      `json:"exported_at"`
+	Posts      []ArchivedPost `json:"posts"`
+}
+
+type ArchivedPost struct {
+	File   string `json:"file"`
+	Title  string `json:"title"`
+	Author string `json:"author"`
+}
+
+// frontMatter is the YAML header of an archived blog, whose content follows
+// it unchanged.
+type frontMatter struct {
+	Title     string     `yaml:"title"`
+	Author    string     `yaml:"author"`
+	CreatedAt time.Time  `yaml:"created_at"`
+	UpdatedAt time.Time  `yaml:"updated_at"`
+	Tags      []string   `yaml:"tags"`
+	Status    string     `yaml:"status"`
+	PublishAt *time.Time `yaml:"publish_at,omitempty"`
+	Format    string     `yaml:"format"`
+}
+
+// ImportReport counts what ImportArchive did with the blogs of an archive.
+// Unchanged blogs were already there as archived; Errors names the blogs
+// that could not be imported and why.
+type ImportReport struct {
+	Created   int      `json:"created"`
+	Updated   int      `json:"updated"`
+	Renamed   int      `json:"renamed"`
+	Skipped   int      `json:"skipped"`
+	Unchanged int      `json:"unchanged"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+// ArchiveService moves blogs between installations as zip archives of
+// Markdown files with YAML front matter.
+type ArchiveService struct {
+	BlogService *BlogService
+	UserRepo    *repository.UserRepository
+}
+
+func NewArchiveService(blogService *BlogService, userRepo *repository.UserRepository) *ArchiveService {
+	return &ArchiveService{BlogService: blogService, UserRepo: userRepo}
+}
+
+// ExportArchive writes every blog outside the trash to w as a zip archive
+// holding one posts/<id>-<slug>.md file per blog and a manifest.json.
+func (service *ArchiveService) ExportArchive(w io.Writer) error {
+	blogs, err := service.allBlogs()
+	if err != nil {
+		return err
+	}
+
+	archive := zip.NewWriter(w)
+	manifest := ArchiveManifest{Version: archiveVersion, ExportedAt: time.Now().UTC().Truncate(time.Second), Posts: []ArchivedPost{}}
+	for _, blog := range blogs {
+		header, err := yaml.Marshal(frontMatter{
+			Title:     blog.Title,
+			Author:    blog.Author,
+			CreatedAt: blog.CreatedAt,
+			UpdatedAt: blog.UpdatedAt,
+			Tags:      blog.Tags,
+			Status:    blog.Status,
+			PublishAt: blog.PublishAt,
+			Format:    blog.Format,
+		})
+		if err != nil {
+			return err
+		}
+
+		name := fmt.Sprintf("posts/%d-%s.md", blog.ID, slug(blog.Title))
+		file, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: blog.UpdatedAt})
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(file, "---\n%s---\n%s", header, blog.Content); err != nil {
+			return err
+		}
+		manifest.Posts = append(manifest.Posts, ArchivedPost{File: name, Title: blog.Title, Author: blog.Author})
+	}
+
+	file, err := archive.CreateHeader(&zip.FileHeader{Name: manifestFile, Method: zip.Deflate, Modified: manifest.ExportedAt})
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(manifest); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+// ImportArchive imports the blogs of an archive written by ExportArchive,
+// keeping their authors and timestamps. A blog is matched to the existing
+// blog of its author with the same title, and left alone when it is the
+// same, so importing an archive twice changes nothing. When they differ,
+// conflict says whether to skip the archived blog, overwrite the existing
+// one or import it under a new title. Blogs whose author does not exist here
+// are reported as errors, as are invalid ones; the others are imported
+// regardless.
+func (service *ArchiveService) ImportArchive(r io.ReaderAt, size int64, conflict string) (*ImportReport, error) {
+	switch conflict {
+	case ConflictSkip, ConflictOverwrite, ConflictRename:
+	default:
+		return nil, ErrInvalidConflict
+	}
+
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	var manifest ArchiveManifest
+	if err := readArchived(files, manifestFile, func(data []byte) error { return json.Unmarshal(data, &manifest) }); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	if manifest.Version != archiveVersion {
+		return nil, fmt.Errorf("%w: unsupported archive version %d", ErrInvalidArchive, manifest.Version)
+	}
+
+	existing, err := service.allBlogs()
+	if err != nil {
+		return nil, err
+	}
+	byTitle := make(map[string]*model.Blog, len(existing))
+	for i := range existing {
+		byTitle[titleKey(existing[i].AuthorID, existing[i].Title)] = &existing[i]
+	}
+
+	report := &ImportReport{}
+	authors := make(map[string]*model.User)
+	for _, post := range manifest.Posts {
+		if err := service.importPost(files, post.File, conflict, byTitle, authors, report); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", post.File, err))
+		}
+	}
+	return report, nil
+}
+
+func (service *ArchiveService) importPost(files map[string]*zip.File, name, conflict string,
+	byTitle map[string]*model.Blog, authors map[string]*model.User, report *ImportReport) error {
+	if !isPostFile(name) {
+		return errors.New("not a post of the archive")
+	}
+	var post frontMatter
+	var content string
+	if err := readArchived(files, name, func(data []byte) (err error) {
+		post, content, err = parsePost(data)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	author, ok := authors[post.Author]
+	if !ok {
+		user, err := service.UserRepo.GetUserByUsername(post.Author)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("author %q does not exist", post.Author)
+		}
+		if err != nil {
+			return err
+		}
+		author, authors[post.Author] = user, user
+	}
+
+	blog := &model.Blog{
+		Title:     post.Title,
+		Content:   content,
+		CreatedAt: post.CreatedAt,
+		UpdatedAt: post.UpdatedAt,
+		Tags:      post.Tags,
+		Status:    post.Status,
+		PublishAt: post.PublishAt,
+		Format:    post.Format,
+	}
+	if blog.Tags == nil {
+		blog.Tags = []string{}
+	}
+	// A post scheduled for a time that has passed since the export would
+	// have been published by then
+	if blog.Status == model.StatusScheduled && blog.PublishAt != nil && !blog.PublishAt.After(time.Now()) {
+		blog.Status = model.StatusPublished
+		if blog.UpdatedAt.Before(*blog.PublishAt) {
+			blog.UpdatedAt = *blog.PublishAt
+		}
+	}
+
+	counter := &report.Created
+	current := byTitle[titleKey(author.ID, blog.Title)]
+	switch {
+	case current == nil:
+	case sameBlog(current, blog):
+		report.Unchanged++
+		return nil
+	case conflict == ConflictSkip:
+		report.Skipped++
+		return nil
+	case conflict == ConflictOverwrite:
+		blog.ID, blog.Version = current.ID, current.Version
+		updated, err := service.BlogService.UpdateBlog(blog, author)
+		if err != nil {
+			return err
+		}
+		byTitle[titleKey(author.ID, updated.Title)] = updated
+		report.Updated++
+		return nil
+	case conflict == ConflictRename:
+		// Take the first free title, or stop at one imported before
+		for n := 2; ; n++ {
+			blog.Title = fmt.Sprintf("%s (%d)", post.Title, n)
+			current = byTitle[titleKey(author.ID, blog.Title)]
+			if current == nil {
+				break
+			}
+			if sameBlog(current, blog) {
+				report.Unchanged++
+				return nil
+			}
+		}
+		counter = &report.Renamed
+	}
+
+	created, err := service.BlogService.createBlog(blog, author)
+	if err != nil {
+		return err
+	}
+	byTitle[titleKey(author.ID, created.Title)] = created
+	*counter++
+	return nil
+}
+
+// allBlogs returns every blog outside the trash, oldest first.
+func (service *ArchiveService) allBlogs() ([]model.Blog, error) {
+	opts := model.BlogListOptions{Limit: exportPageSize, Sort: model.SortOldest, Viewer: &model.User{IsAdmin: true}}
+	var blogs []model.Blog
+	for {
+		page, err := service.BlogService.GetAllBlogs(opts)
+		if err != nil {
+			return nil, err
+		}
+		blogs = append(blogs, page.Data...)
+		if page.NextCursor == "" {
+			return blogs, nil
+		}
+		opts.After = page.NextCursor
+	}
+}
+
+// readArchived reads a file of the archive, refusing ones too large to be a
+// blog, and hands its content to parse.
+func readArchived(files map[string]*zip.File, name string, parse func(data []byte) error) error {
+	file, ok := files[name]
+	if !ok {
+		return fmt.Errorf("%s is missing", name)
+	}
+	if file.UncompressedSize64 > maxArchivedPost {
+		return fmt.Errorf("%s is larger than %d bytes", name, maxArchivedPost)
+	}
+	rc, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, maxArchivedPost+1))
+	if err != nil {
+		return err
+	}
+	if len(data) > maxArchivedPost {
+		return fmt.Errorf("%s is larger than %d bytes", name, maxArchivedPost)
+	}
+	return parse(data)
+}
+
+// isPostFile reports whether name is a file ExportArchive would write, a
+// Markdown file right in posts/, so that a manifest cannot point elsewhere.
+func isPostFile(name string) bool {
+	return path.Clean(name) == name && path.Dir(name) == "posts" && path.Ext(name) == ".md"
+}
+
+// parsePost splits an archived blog into its front matter and content.
+func parsePost(data []byte) (frontMatter, string, error) {
+	var post frontMatter
+	rest, ok := bytes.CutPrefix(data, []byte("---\n"))
+	if !ok {
+		return post, "", errors.New("front matter is missing")
+	}
+	header, content, ok := bytes.Cut(rest, []byte("\n---\n"))
+	if !ok {
+		return post, "", errors.New("front matter is not closed by ---")
+	}
+	if err := yaml.Unmarshal(header, &post); err != nil {
+		return post, "", fmt.Errorf("invalid front matter: %v", err)
+	}
+	if strings.TrimSpace(post.Title) == "" || post.Author == "" {
+		return post, "", errors.New("front matter needs a title and an author")
+	}
+	return post, string(content), nil
+}
+
+// sameBlog reports whether blog already holds what other would store.
+func sameBlog(blog, other *model.Blog) bool {
+	format := cmp.Or(other.Format, model.FormatMarkdown)
+	status := cmp.Or(other.Status, model.StatusPublished)
+	tags, err := normalizeTags(other.Tags)
+	return err == nil && blog.Title == other.Title && blog.Content == other.Content && blog.Status == status &&
+		blog.Format == format && slices.Equal(blog.Tags, tags)
+}
+
+func titleKey(authorID int, title string) string {
+	return strconv.Itoa(authorID) + "\x00" + title
+}
+
+// slug turns a title into a file name, keeping letters and digits.
+func slug(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+		if b.Len() >= 50 {
+			break
+		}
+	}
+	s := strings.TrimSuffix(b.String(), "-")
+	if s == "" {
+		return "post"
+	}
+	return s
+}
@@ -0,0 +1,293 @@
+package service
+
+import (
+	"archive/zip"
+	"blogmanager/config/dbtest"
+	"blogmanager/model"
+	"blogmanager/repository"
+	"bytes"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// installation is an empty blog manager with its own database.
+type installation struct {
+	Archive *ArchiveService
+	Blogs   *BlogService
+	Users   *UserService
+}
+
+func newInstallation(t *testing.T) installation {
+	t.Helper()
+	database := dbtest.Open(t)
+	userRepo := repository.NewUserRepository(database, repository.SQLite)
+	blogs := NewBlogService(repository.NewBlogRepository(database, repository.SQLite))
+	return installation{Archive: NewArchiveService(blogs, userRepo), Blogs: blogs, Users: NewUserService(userRepo)}
+}
+
+func (inst installation) user(t *testing.T, username string) *model.User {
+	t.Helper()
+	user, err := inst.Users.RegisterUser(username, "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func (inst installation) export(t *testing.T) []byte {
+	t.Helper()
+	var archive bytes.Buffer
+	if err := inst.Archive.ExportArchive(&archive); err != nil {
+		t.Fatal(err)
+	}
+	return archive.Bytes()
+}
+
+func (inst installation) importArchive(t *testing.T, archive []byte, conflict string) *ImportReport {
+	t.Helper()
+	report, err := inst.Archive.ImportArchive(bytes.NewReader(archive), int64(len(archive)), conflict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+// blogsByTitle returns every blog outside the trash.
+func (inst installation) blogsByTitle(t *testing.T) map[string]model.Blog {
+	t.Helper()
+	blogs, err := inst.Archive.allBlogs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	byTitle := make(map[string]model.Blog, len(blogs))
+	for _, blog := range blogs {
+		byTitle[blog.Title] = blog
+	}
+	return byTitle
+}
+
+// zipArchive writes an archive holding files, in order.
+func zipArchive(t *testing.T, files ...[2]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for _, file := range files {
+		w, err := archive.Create(file[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(file[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func manifestOf(files ...string) string {
+	var posts []string
+	for _, file := range files {
+		posts = append(posts, `{"file": "`+file+`", "title": "", "author": "alice"}`)
+	}
+	return `{"version": 1, "exported_at": "2024-01-01T00:00:00Z", "posts": [` + strings.Join(posts, ",") + `]}`
+}
+
+const archivedPost = "---\ntitle: Hello\nauthor: alice\ncreated_at: 2024-01-01T00:00:00Z\nupdated_at: 2024-01-01T00:00:00Z\n---\nHello from the archive"
+
+func TestArchiveRoundTrip(t *testing.T) {
+	source := newInstallation(t)
+	alice, bob := source.user(t, "alice"), source.user(t, "bob")
+	publishAt := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	for _, blog := range []struct {
+		blog   model.Blog
+		author *model.User
+	}{
+		{model.Blog{Title: "Hello, world!", Content: "# Hi\n\nFirst post", Tags: []string{"go", "intro"}}, alice},
+		{model.Blog{Title: "Notes", Content: "Plain notes", Format: model.FormatPlain, Status: model.StatusDraft}, alice},
+		{model.Blog{Title: "Later", Content: "Scheduled", Status: model.StatusScheduled, PublishAt: &publishAt}, alice},
+		{model.Blog{Title: "Bob's", Content: "By bob"}, bob},
+	} {
+		if _, err := source.Blogs.CreateBlog(&blog.blog, blog.author); err != nil {
+			t.Fatal(err)
+		}
+	}
+	archive := source.export(t)
+
+	// Bob has no account at the destination, so his blog is not imported
+	destination := newInstallation(t)
+	destination.user(t, "alice")
+	report := destination.importArchive(t, archive, ConflictSkip)
+	if report.Created != 3 || len(report.Errors) != 1 || !strings.Contains(report.Errors[0], `author "bob" does not exist`) {
+		t.Errorf("first import: %+v", report)
+	}
+
+	want, got := source.blogsByTitle(t), destination.blogsByTitle(t)
+	delete(want, "Bob's")
+	if len(got) != len(want) {
+		t.Fatalf("imported %d blogs, want %d", len(got), len(want))
+	}
+	for title, blog := range want {
+		imported := got[title]
+		if imported.Content != blog.Content || imported.Status != blog.Status || imported.Format != blog.Format ||
+			!slices.Equal(imported.Tags, blog.Tags) || !imported.CreatedAt.Equal(blog.CreatedAt) ||
+			!imported.UpdatedAt.Equal(blog.UpdatedAt) || !sameTime(imported.PublishAt, blog.PublishAt) {
+			t.Errorf("imported %+v, want %+v", imported, blog)
+		}
+	}
+
+	// Importing the same archive again changes nothing
+	report = destination.importArchive(t, archive, ConflictOverwrite)
+	if report.Unchanged != 3 || report.Created+report.Updated+report.Renamed+report.Skipped != 0 {
+		t.Errorf("second import: %+v", report)
+	}
+}
+
+// TestImportPastDueScheduledPost checks that a post whose schedule passed
+// between the export and the import comes in as published at its schedule,
+// as the publisher would have left it.
+func TestImportPastDueScheduledPost(t *testing.T) {
+	post := "---\ntitle: Due\nauthor: alice\ncreated_at: 2024-01-01T00:00:00Z\nupdated_at: 2024-01-01T00:00:00Z\n" +
+		"status: scheduled\npublish_at: 2024-02-01T00:00:00Z\n---\nPublished while away"
+	future := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	later := "---\ntitle: Later\nauthor: alice\nstatus: scheduled\npublish_at: " + future.Format(time.RFC3339) + "\n---\nNot yet"
+	archive := zipArchive(t, [2]string{"posts/1-due.md", post}, [2]string{"posts/2-later.md", later},
+		[2]string{manifestFile, manifestOf("posts/1-due.md", "posts/2-later.md")})
+
+	inst := newInstallation(t)
+	inst.user(t, "alice")
+	if report := inst.importArchive(t, archive, ConflictSkip); report.Created != 2 || len(report.Errors) > 0 {
+		t.Fatalf("report %+v, want both posts created", report)
+	}
+	blogs := inst.blogsByTitle(t)
+	publishAt := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	due := blogs["Due"]
+	if due.Status != model.StatusPublished || due.PublishAt == nil || !due.PublishAt.Equal(publishAt) || !due.UpdatedAt.Equal(publishAt) {
+		t.Errorf("past-due post imported as %s at %v, updated %v, want published at %v", due.Status, due.PublishAt, due.UpdatedAt, publishAt)
+	}
+	if blog := blogs["Later"]; blog.Status != model.StatusScheduled || blog.PublishAt == nil || !blog.PublishAt.Equal(future) {
+		t.Errorf("post due later imported as %s at %v, want scheduled at %v", blog.Status, blog.PublishAt, future)
+	}
+
+	// Importing it again finds it unchanged
+	if report := inst.importArchive(t, archive, ConflictRename); report.Unchanged != 2 || len(report.Errors) > 0 {
+		t.Errorf("report of a second import %+v, want both unchanged", report)
+	}
+}
+
+func TestImportConflicts(t *testing.T) {
+	archive := zipArchive(t, [2]string{"posts/1-hello.md", archivedPost}, [2]string{manifestFile, manifestOf("posts/1-hello.md")})
+	tests := []struct {
+		conflict   string
+		wantReport ImportReport
+		wantBlogs  map[string]string
+	}{
+		{ConflictSkip, ImportReport{Skipped: 1}, map[string]string{"Hello": "Already here"}},
+		{ConflictOverwrite, ImportReport{Updated: 1}, map[string]string{"Hello": "Hello from the archive"}},
+		{ConflictRename, ImportReport{Renamed: 1},
+			map[string]string{"Hello": "Already here", "Hello (2)": "Hello from the archive"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.conflict, func(t *testing.T) {
+			inst := newInstallation(t)
+			alice := inst.user(t, "alice")
+			if _, err := inst.Blogs.CreateBlog(&model.Blog{Title: "Hello", Content: "Already here"}, alice); err != nil {
+				t.Fatal(err)
+			}
+
+			report := inst.importArchive(t, archive, tt.conflict)
+			if report.Created != tt.wantReport.Created || report.Updated != tt.wantReport.Updated ||
+				report.Renamed != tt.wantReport.Renamed || report.Skipped != tt.wantReport.Skipped || len(report.Errors) > 0 {
+				t.Errorf("report %+v, want %+v", report, tt.wantReport)
+			}
+			blogs := inst.blogsByTitle(t)
+			if len(blogs) != len(tt.wantBlogs) {
+				t.Errorf("%d blogs after the import, want %d", len(blogs), len(tt.wantBlogs))
+			}
+			for title, content := range tt.wantBlogs {
+				if blogs[title].Content != content {
+					t.Errorf("%q holds %q, want %q", title, blogs[title].Content, content)
+				}
+			}
+
+			// A renamed blog is found again rather than renamed once more
+			if report := inst.importArchive(t, archive, tt.conflict); tt.conflict != ConflictSkip && report.Unchanged != 1 {
+				t.Errorf("second import: %+v", report)
+			}
+		})
+	}
+}
+
+func TestImportRejectsInvalidArchives(t *testing.T) {
+	tests := []struct {
+		name     string
+		archive  []byte
+		conflict string
+		want     error
+	}{
+		{"not a zip", []byte("not a zip"), ConflictSkip, ErrInvalidArchive},
+		{"no manifest", zipArchive(t, [2]string{"posts/1-hello.md", archivedPost}), ConflictSkip, ErrInvalidArchive},
+		{"invalid manifest", zipArchive(t, [2]string{manifestFile, "{"}), ConflictSkip, ErrInvalidArchive},
+		{"unknown version", zipArchive(t, [2]string{manifestFile, `{"version": 2}`}), ConflictSkip, ErrInvalidArchive},
+		{"unknown conflict", zipArchive(t, [2]string{manifestFile, manifestOf()}), "merge", ErrInvalidConflict},
+	}
+	inst := newInstallation(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := inst.Archive.ImportArchive(bytes.NewReader(tt.archive), int64(len(tt.archive)), tt.conflict)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("ImportArchive = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestImportRejectsInvalidPosts checks that bad posts are reported while the
+// others are still imported.
+func TestImportRejectsInvalidPosts(t *testing.T) {
+	huge := "---\ntitle: Huge\nauthor: alice\n---\n" + strings.Repeat("a", maxArchivedPost)
+	posts := []struct {
+		file, content, wantErr string
+	}{
+		{"../../etc/passwd", archivedPost, "not a post of the archive"},
+		{"posts/../../escape.md", archivedPost, "not a post of the archive"},
+		{"/posts/absolute.md", archivedPost, "not a post of the archive"},
+		{"posts/huge.md", huge, "is larger than"},
+		{"posts/no-front-matter.md", "Just content", "front matter is missing"},
+		{"posts/unclosed.md", "---\ntitle: Open\n", "not closed"},
+		{"posts/no-author.md", "---\ntitle: Anonymous\n---\nContent", "needs a title and an author"},
+		{"posts/missing.md", "", "is missing"},
+		{"posts/1-hello.md", archivedPost, ""},
+	}
+	var files [][2]string
+	var names []string
+	for _, post := range posts {
+		if post.file != "posts/missing.md" {
+			files = append(files, [2]string{post.file, post.content})
+		}
+		names = append(names, post.file)
+	}
+	archive := zipArchive(t, append(files, [2]string{manifestFile, manifestOf(names...)})...)
+
+	inst := newInstallation(t)
+	inst.user(t, "alice")
+	report := inst.importArchive(t, archive, ConflictSkip)
+	if report.Created != 1 {
+		t.Errorf("created %d blogs, want 1", report.Created)
+	}
+	if len(report.Errors) != len(posts)-1 {
+		t.Fatalf("errors %q, want one per invalid post", report.Errors)
+	}
+	for i, post := range posts[:len(posts)-1] {
+		if !strings.HasPrefix(report.Errors[i], post.file+":") || !strings.Contains(report.Errors[i], post.wantErr) {
+			t.Errorf("error %q, want %s: ...%s...", report.Errors[i], post.file, post.wantErr)
+		}
+	}
+	if blogs := inst.blogsByTitle(t); len(blogs) != 1 || blogs["Hello"].Content != "Hello from the archive" {
+		t.Errorf("blogs after the import: %v", blogs)
+	}
+}
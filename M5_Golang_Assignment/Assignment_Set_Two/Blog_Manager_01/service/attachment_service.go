@@ -0,0 +1,180 @@
+package service
+
+import (
+	"blogmanager/model"
+	"blogmanager/repository"
+	"blogmanager/storage"
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	ErrMediaNotFound          = errors.New("media not found")
+	ErrUnsupportedMediaType   = errors.New("attachments must be PNG, JPEG, GIF or WebP images or PDF documents")
+	ErrInvalidAttachmentName  = errors.New("attachment must have a filename")
+	allowedAttachmentTypes    = []string{"image/png", "image/jpeg", "image/gif", "image/webp", "application/pdf"}
+	unreferencedFileRetention = time.Hour
+)
+
+const maxFilenameLength = 255
+
+type AttachmentService struct {
+	AttachmentRepo *repository.AttachmentRepository
+	BlogStore      repository.BlogStore
+	Storage        *storage.LocalStorage
+}
+
+func NewAttachmentService(attachmentRepo *repository.AttachmentRepository, blogStore repository.BlogStore,
+	localStorage *storage.LocalStorage) *AttachmentService {
+	return &AttachmentService{AttachmentRepo: attachmentRepo, BlogStore: blogStore, Storage: localStorage}
+}
+
+// Upload stores a file as an attachment of a blog the user owns. Its type is
+// sniffed from its content; the type claimed by the client is ignored.
+func (service *AttachmentService) Upload(blogID int, user *model.User, filename string, r io.Reader) (*model.Attachment, error) {
+	blog, err := service.BlogStore.GetBlog(blogID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrBlogNotFound
+		}
+		return nil, err
+	}
+	if !owns(user, blog) {
+		return nil, ErrForbidden
+	}
+
+	filename, err = cleanFilename(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	head = head[:n]
+	contentType := http.DetectContentType(head)
+	if !slices.Contains(allowedAttachmentTypes, contentType) {
+		return nil, ErrUnsupportedMediaType
+	}
+
+	hash, size, err := service.Storage.Save(io.MultiReader(bytes.NewReader(head), r))
+	if err != nil {
+		return nil, err
+	}
+
+	attachment, err := service.AttachmentRepo.CreateAttachment(&model.Attachment{
+		BlogID:      blogID,
+		Hash:        hash,
+		Filename:    filename,
+		ContentType: contentType,
+		Size:        size,
+	})
+	if err != nil {
+		return nil, err
+	}
+	attachment.URL = mediaURL(hash)
+	return attachment, nil
+}
+
+// GetAttachments lists the attachments of a blog the viewer can see.
+func (service *AttachmentService) GetAttachments(blogID int, viewer *model.User) ([]model.Attachment, error) {
+	blog, err := service.BlogStore.GetBlog(blogID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrBlogNotFound
+		}
+		return nil, err
+	}
+	if !canView(blog, viewer) {
+		return nil, ErrBlogNotFound
+	}
+
+	attachments, err := service.AttachmentRepo.GetAttachmentsByBlog(blogID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range attachments {
+		attachments[i].URL = mediaURL(attachments[i].Hash)
+	}
+	return attachments, nil
+}
+
+// OpenMedia opens the file with the given hash together with its content
+// type. Files are only served when attached to a blog the viewer can see, so
+// those of drafts, scheduled and trashed blogs stay private.
+func (service *AttachmentService) OpenMedia(hash string, viewer *model.User) (*os.File, string, error) {
+	if !storage.ValidHash(hash) {
+		return nil, "", ErrMediaNotFound
+	}
+	contentType, err := service.AttachmentRepo.GetMediaType(hash, viewer)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, "", ErrMediaNotFound
+		}
+		return nil, "", err
+	}
+
+	file, err := service.Storage.Open(hash)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, "", ErrMediaNotFound
+		}
+		return nil, "", err
+	}
+	return file, contentType, nil
+}
+
+// RemoveUnreferenced deletes the stored files no attachment refers to any
+// more, such as those of purged blogs.
+func (service *AttachmentService) RemoveUnreferenced(ctx context.Context) {
+	referenced, err := service.AttachmentRepo.ReferencedHashes()
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to list attached files", "error", err)
+		return
+	}
+
+	removed, err := service.Storage.RemoveUnreferenced(referenced, unreferencedFileRetention)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to remove unreferenced files", "error", err)
+	}
+	if removed > 0 {
+		slog.InfoContext(ctx, "removed unreferenced files", "count", removed)
+	}
+}
+
+// cleanFilename keeps the last element of a filename sent as a path, with
+// either slash, and shortens it to maxFilenameLength runes, keeping its
+// extension.
+func cleanFilename(filename string) (string, error) {
+	filename = path.Base(path.Clean("/" + strings.ReplaceAll(filename, `\`, "/")))
+	if filename == "/" {
+		return "", ErrInvalidAttachmentName
+	}
+	if utf8.RuneCountInString(filename) <= maxFilenameLength {
+		return filename, nil
+	}
+
+	ext := path.Ext(filename)
+	if utf8.RuneCountInString(ext) >= maxFilenameLength/2 {
+		ext = ""
+	}
+	stem := []rune(strings.TrimSuffix(filename, ext))
+	return string(stem[:maxFilenameLength-utf8.RuneCountInString(ext)]) + ext, nil
+}
+
+func mediaURL(hash string) string {
+	return "/media/" + hash
+}
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"blogmanager/model"
+	"blogmanager/repository"
+	"blogmanager/storage"
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pngContent is sniffed as a PNG image.
+var pngContent = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01")
+
+type attachmentFixture struct {
+	installation
+	Attachments *AttachmentService
+	Dir         string
+}
+
+func newAttachmentFixture(t *testing.T) attachmentFixture {
+	t.Helper()
+	inst := newInstallation(t)
+	dir := t.TempDir()
+	mediaStorage, err := storage.NewLocalStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attachmentRepo := repository.NewAttachmentRepository(inst.Blogs.BlogStore.(*repository.BlogRepository).DB, repository.SQLite)
+	attachments := NewAttachmentService(attachmentRepo, inst.Blogs.BlogStore, mediaStorage)
+	inst.Blogs.OnPurge = attachments.RemoveUnreferenced
+	return attachmentFixture{installation: inst, Attachments: attachments, Dir: dir}
+}
+
+func (f attachmentFixture) storedFiles(t *testing.T) []string {
+	t.Helper()
+	entries, err := os.ReadDir(f.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestCleanFilename(t *testing.T) {
+	long := strings.Repeat("é", 300)
+	tests := []struct {
+		name, filename, want string
+		err                  error
+	}{
+		{"plain", "cat.png", "cat.png", nil},
+		{"unix path", "/home/alice/cat.png", "cat.png", nil},
+		{"windows path", `C:\Users\alice\cat.png`, "cat.png", nil},
+		{"traversal", "../../etc/passwd", "passwd", nil},
+		{"trailing slash", "images/", "images", nil},
+		{"empty", "", "", ErrInvalidAttachmentName},
+		{"only slashes", "//", "", ErrInvalidAttachmentName},
+		{"dot dot", "..", "", ErrInvalidAttachmentName},
+		{"long", long + ".png", strings.Repeat("é", 251) + ".png", nil},
+		{"long extension", "a." + long, ("a." + long)[:len("a.")+253*len("é")], nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cleanFilename(tt.filename)
+			if got != tt.want || !errors.Is(err, tt.err) {
+				t.Errorf("cleanFilename(%q) = %q, %v, want %q, %v", tt.filename, got, err, tt.want, tt.err)
+			}
+		})
+	}
+}
+
+func TestUploadSniffsContent(t *testing.T) {
+	f := newAttachmentFixture(t)
+	alice := f.user(t, "alice")
+	blog, err := f.Blogs.CreateBlog(&model.Blog{Title: "Pictures", Content: "Content"}, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	disguised := []byte("<html><script>alert(1)</script></html>")
+	if _, err := f.Attachments.Upload(blog.ID, alice, "cat.png", bytes.NewReader(disguised)); !errors.Is(err, ErrUnsupportedMediaType) {
+		t.Errorf("Upload of HTML named cat.png = %v, want %v", err, ErrUnsupportedMediaType)
+	}
+	attachment, err := f.Attachments.Upload(blog.ID, alice, "cat.txt", bytes.NewReader(pngContent))
+	if err != nil || attachment.ContentType != "image/png" {
+		t.Errorf("Upload of a PNG named cat.txt = %+v, %v, want image/png", attachment, err)
+	}
+
+	bob := f.user(t, "bob")
+	if _, err := f.Attachments.Upload(blog.ID, bob, "cat.png", bytes.NewReader(pngContent)); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Upload to another user's blog = %v, want %v", err, ErrForbidden)
+	}
+}
+
+func TestUploadStoresContentOnce(t *testing.T) {
+	f := newAttachmentFixture(t)
+	alice := f.user(t, "alice")
+	first, err := f.Blogs.CreateBlog(&model.Blog{Title: "First", Content: "Content"}, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := f.Blogs.CreateBlog(&model.Blog{Title: "Second", Content: "Content"}, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := f.Attachments.Upload(first.ID, alice, "a.png", bytes.NewReader(pngContent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := f.Attachments.Upload(second.ID, alice, "b.png", bytes.NewReader(pngContent))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The SHA-256 of pngContent
+	const hash = "a930c2bb4e61c0682068f71c4ef427eefbb07098ecea9390e445e7af4b66a384"
+	if a.Hash != hash || b.Hash != hash || a.URL != "/media/"+hash || a.Size != int64(len(pngContent)) {
+		t.Errorf("attachments of the same content: %+v and %+v, want hash %s", a, b, hash)
+	}
+	if files := f.storedFiles(t); len(files) != 1 || files[0] != hash {
+		t.Errorf("stored files = %v, want only %s", files, hash)
+	}
+	stored, err := os.ReadFile(filepath.Join(f.Dir, hash))
+	if err != nil || !bytes.Equal(stored, pngContent) {
+		t.Errorf("stored file = %q, %v, want the upload", stored, err)
+	}
+}
+
+func TestOpenMediaFollowsBlogVisibility(t *testing.T) {
+	f := newAttachmentFixture(t)
+	alice, bob := f.user(t, "alice"), f.user(t, "bob")
+	upload := func(status string) string {
+		t.Helper()
+		blog, err := f.Blogs.CreateBlog(&model.Blog{Title: status, Content: status, Status: status}, alice)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Tell the files apart so that each belongs to one blog only
+		attachment, err := f.Attachments.Upload(blog.ID, alice, "a.png", bytes.NewReader(append(pngContent, status...)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return attachment.Hash
+	}
+	published, draft := upload(model.StatusPublished), upload(model.StatusDraft)
+
+	tests := []struct {
+		name   string
+		hash   string
+		viewer *model.User
+		found  bool
+	}{
+		{"published, anonymous", published, nil, true},
+		{"draft, anonymous", draft, nil, false},
+		{"draft, another user", draft, bob, false},
+		{"draft, author", draft, alice, true},
+		{"draft, admin", draft, &model.User{ID: 99, IsAdmin: true}, true},
+		{"unknown", strings.Repeat("0", 64), alice, false},
+		{"invalid", "../blogs.db", alice, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, contentType, err := f.Attachments.OpenMedia(tt.hash, tt.viewer)
+			if tt.found {
+				if err != nil || contentType != "image/png" {
+					t.Fatalf("OpenMedia = %q, %v, want image/png", contentType, err)
+				}
+				file.Close()
+			} else if !errors.Is(err, ErrMediaNotFound) {
+				t.Errorf("OpenMedia = %v, want %v", err, ErrMediaNotFound)
+			}
+		})
+	}
+}
+
+func TestPurgeRemovesUnreferencedFiles(t *testing.T) {
+	retention := unreferencedFileRetention
+	unreferencedFileRetention = 0
+	t.Cleanup(func() { unreferencedFileRetention = retention })
+
+	f := newAttachmentFixture(t)
+	alice := f.user(t, "alice")
+	trashed, err := f.Blogs.CreateBlog(&model.Blog{Title: "Trashed", Content: "Content"}, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, err := f.Blogs.CreateBlog(&model.Blog{Title: "Kept", Content: "Content"}, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orphan, err := f.Attachments.Upload(trashed.ID, alice, "orphan.png", bytes.NewReader(append(pngContent, "orphan"...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared, err := f.Attachments.Upload(trashed.ID, alice, "shared.png", bytes.NewReader(pngContent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Attachments.Upload(kept.ID, alice, "shared.png", bytes.NewReader(pngContent)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.Blogs.DeleteBlog(trashed.ID, 0, alice); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := f.Attachments.OpenMedia(orphan.Hash, alice); !errors.Is(err, ErrMediaNotFound) {
+		t.Errorf("OpenMedia of a trashed blog's file = %v, want %v", err, ErrMediaNotFound)
+	}
+	if files := f.storedFiles(t); len(files) != 2 {
+		t.Fatalf("stored files before the purge = %v, want both kept while in the trash", files)
+	}
+
+	// One round of the purger, with a retention that purges the whole trash
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	f.Blogs.PurgeTrash(ctx, time.Hour, -time.Second)
+	if files := f.storedFiles(t); len(files) != 1 || files[0] != shared.Hash {
+		t.Errorf("stored files after the purge = %v, want only %s", files, shared.Hash)
+	}
+}
@@ -3,32 +3,444 @@ package service
 import (
 	"blogmanager/model"
 	"blogmanager/repository"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	ErrBlogNotFound = errors.New("blog not found")
+	ErrInvalidBlog  = errors.New("a blog needs a title and content")
+	ErrForbidden    = errors.New("only the author or an admin can modify this blog")
+	ErrEmptyQuery   = errors.New("search query must contain at least one word")
+	ErrInvalidSort  = errors.New("sort must be one of newest, oldest or title")
+	ErrInvalidRange = errors.New("from must not be after to")
+	ErrInvalidTags  = fmt.Errorf("a blog can have at most %d tags of up to %d characters", maxTags, maxTagLength)
+
+	ErrInvalidStatus   = errors.New("status must be one of draft, scheduled, published or archived")
+	ErrInvalidSchedule = errors.New("scheduled blogs need a publish_at in the future")
+
+	ErrRevisionNotFound = errors.New("revision not found")
+	ErrInvalidFormat    = errors.New("format must be one of markdown, plain or html")
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+	defaultPageSize    = 20
+	maxPageSize        = 100
+	maxTags            = 10
+	maxTagLength       = 32
 )
 
 type BlogService struct {
-	BlogRepo *repository.BlogRepository
+	BlogStore repository.BlogStore
+
+	// OnPurge, when set, runs after PurgeTrash has deleted blogs for good
+	OnPurge func(ctx context.Context)
+
+	publisher heartbeat
+	purger    heartbeat
+}
+
+func NewBlogService(blogStore repository.BlogStore) *BlogService {
+	return &BlogService{BlogStore: blogStore}
+}
+
+// CreateBlog stores a blog owned by the given user.
+func (service *BlogService) CreateBlog(blog *model.Blog, user *model.User) (*model.Blog, error) {
+	blog.CreatedAt, blog.UpdatedAt = time.Time{}, time.Time{}
+	return service.createBlog(blog, user)
+}
+
+// createBlog stores a blog owned by the given user, keeping its timestamps
+// when they are set.
+func (service *BlogService) createBlog(blog *model.Blog, user *model.User) (*model.Blog, error) {
+	if err := validateBlog(blog); err != nil {
+		return nil, err
+	}
+	tags, err := normalizeTags(blog.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	if blog.Status == "" {
+		blog.Status = model.StatusPublished
+	}
+	if err := applyStatus(blog, time.Now()); err != nil {
+		return nil, err
+	}
+
+	if blog.Format == "" {
+		blog.Format = model.FormatMarkdown
+	}
+	if blog.HTML, err = renderHTML(blog.Format, blog.Content); err != nil {
+		return nil, err
+	}
+
+	blog.Tags = tags
+	blog.AuthorID = user.ID
+	blog.Author = user.Username
+	return service.BlogStore.CreateBlog(blog)
+}
+
+// GetBlog returns a blog if the viewer is allowed to see it.
+func (service *BlogService) GetBlog(id int, viewer *model.User) (*model.Blog, error) {
+	blog, err := service.BlogStore.GetBlog(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrBlogNotFound
+		}
+		return nil, err
+	}
+
+	if !canView(blog, viewer) {
+		return nil, ErrBlogNotFound
+	}
+	return blog, nil
+}
+
+// RenderBlog fills in blog.HTML from the rendering cached when the content
+// was last written, rendering and caching it first if there is none.
+func (service *BlogService) RenderBlog(blog *model.Blog) error {
+	rendered, err := service.BlogStore.GetRenderedHTML(blog.ID)
+	if err != nil {
+		return err
+	}
+
+	if rendered == "" && blog.Content != "" {
+		if rendered, err = renderHTML(blog.Format, blog.Content); err != nil {
+			return err
+		}
+		if err := service.BlogStore.SaveRenderedHTML(blog.ID, rendered); err != nil {
+			return err
+		}
+	}
+
+	blog.HTML = rendered
+	return nil
+}
+
+// GetAllBlogs returns one page of blogs, newest first unless opts.Sort says
+// otherwise.
+func (service *BlogService) GetAllBlogs(opts model.BlogListOptions) (*model.BlogPage, error) {
+	switch opts.Sort {
+	case "":
+		opts.Sort = model.SortNewest
+	case model.SortNewest, model.SortOldest, model.SortTitle:
+	default:
+		return nil, ErrInvalidSort
+	}
+
+	switch opts.Status {
+	case "", model.StatusDraft, model.StatusScheduled, model.StatusPublished, model.StatusArchived:
+	default:
+		return nil, ErrInvalidStatus
+	}
+
+	if !opts.From.IsZero() && !opts.To.IsZero() && opts.From.After(opts.To) {
+		return nil, ErrInvalidRange
+	}
+
+	if opts.Limit <= 0 {
+		opts.Limit = defaultPageSize
+	}
+	opts.Limit = min(opts.Limit, maxPageSize)
+	opts.Tag = strings.ToLower(strings.TrimSpace(opts.Tag))
+	return service.BlogStore.GetAllBlogs(opts)
+}
+
+// SearchBlogs finds blogs whose title or content match the query. Quoted
+// phrases and prefix* terms are supported; all terms must match.
+func (service *BlogService) SearchBlogs(query string, limit int, viewer *model.User) ([]model.BlogSearchResult, error) {
+	terms := repository.ParseSearchQuery(query)
+	if len(terms) == 0 {
+		return nil, ErrEmptyQuery
+	}
+
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	limit = min(limit, maxSearchLimit)
+	return service.BlogStore.Search(terms, limit, viewer)
 }
 
-func NewBlogService(BlogRepo *repository.BlogRepository) *BlogService {
-	return &BlogService{BlogRepo: BlogRepo}
+// GetTags returns every tag in use with its post count.
+func (service *BlogService) GetTags() ([]model.Tag, error) {
+	return service.BlogStore.GetTags()
 }
 
-func (service *BlogService) CreateBlog(blog *model.Blog) (*model.Blog, error) {
-	return service.BlogRepo.CreateBlog(blog)
+// UpdateBlog replaces the title and content of a blog the user owns, its tags
+// when blog.Tags is not nil and its status and format when they are set, then
+// re-renders it. Ownership fields are always taken from the stored blog. A
+// non-zero blog.Version must match the stored one.
+func (service *BlogService) UpdateBlog(blog *model.Blog, user *model.User) (*model.Blog, error) {
+	if err := validateBlog(blog); err != nil {
+		return nil, err
+	}
+	if blog.Tags != nil {
+		tags, err := normalizeTags(blog.Tags)
+		if err != nil {
+			return nil, err
+		}
+		blog.Tags = tags
+	}
+
+	existing, err := service.ownedBlog(blog.ID, user)
+	if err != nil {
+		return nil, err
+	}
+	if blog.Version != 0 && blog.Version != existing.Version {
+		return nil, repository.ErrVersionConflict
+	}
+
+	if blog.Status == "" {
+		blog.Status = existing.Status
+		if blog.PublishAt == nil {
+			blog.PublishAt = existing.PublishAt
+		}
+	} else {
+		// Republishing keeps the original publish time
+		if blog.Status == model.StatusPublished && blog.PublishAt == nil {
+			blog.PublishAt = existing.PublishAt
+		}
+		if err := applyStatus(blog, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	if blog.Format == "" {
+		blog.Format = existing.Format
+	}
+	if blog.HTML, err = renderHTML(blog.Format, blog.Content); err != nil {
+		return nil, err
+	}
+
+	blog.AuthorID = existing.AuthorID
+	blog.Author = existing.Author
+	return service.BlogStore.UpdateBlog(blog)
+}
+
+// DeleteBlog moves a blog the user owns to the trash, provided it is still at
+// version unless that is 0.
+func (service *BlogService) DeleteBlog(id, version int, user *model.User) error {
+	existing, err := service.ownedBlog(id, user)
+	if err != nil {
+		return err
+	}
+	if version != 0 && version != existing.Version {
+		return repository.ErrVersionConflict
+	}
+	if err := service.BlogStore.DeleteBlog(id, version); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrBlogNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// GetRevisions returns the saved revisions of a blog the viewer can see,
+// newest first.
+func (service *BlogService) GetRevisions(blogID int, viewer *model.User) ([]model.BlogRevision, error) {
+	if _, err := service.GetBlog(blogID, viewer); err != nil {
+		return nil, err
+	}
+	return service.BlogStore.GetRevisions(blogID)
+}
+
+// DiffRevision compares a revision with the current version of a blog: its
+// title, status and tags, and its content as a unified diff.
+func (service *BlogService) DiffRevision(blogID, revision int, viewer *model.User) (*model.RevisionDiff, error) {
+	blog, err := service.GetBlog(blogID, viewer)
+	if err != nil {
+		return nil, err
+	}
+
+	rev, err := service.getRevision(blogID, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &model.RevisionDiff{
+		BlogID:   blogID,
+		Revision: rev.Revision,
+		Diff:     unifiedDiff(fmt.Sprintf("revision %d", rev.Revision), "current", rev.Content, blog.Content),
+	}
+	if rev.Title != blog.Title {
+		diff.Title = &model.FieldChange{From: rev.Title, To: blog.Title}
+	}
+	if rev.Status != "" && rev.Status != blog.Status {
+		diff.Status = &model.FieldChange{From: rev.Status, To: blog.Status}
+	}
+	if rev.Tags != nil {
+		added, removed := tagChanges(rev.Tags, blog.Tags)
+		if len(added) > 0 || len(removed) > 0 {
+			diff.Tags = &model.TagsChange{Added: added, Removed: removed}
+		}
+	}
+	return diff, nil
+}
+
+// RestoreRevision makes the title and content of a revision current again.
+// The version being replaced is saved as a new revision like any update.
+func (service *BlogService) RestoreRevision(blogID, revision int, user *model.User) (*model.Blog, error) {
+	if _, err := service.ownedBlog(blogID, user); err != nil {
+		return nil, err
+	}
+
+	rev, err := service.getRevision(blogID, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	return service.UpdateBlog(&model.Blog{ID: blogID, Title: rev.Title, Content: rev.Content}, user)
+}
+
+func (service *BlogService) getRevision(blogID, revision int) (*model.BlogRevision, error) {
+	rev, err := service.BlogStore.GetRevision(blogID, revision)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRevisionNotFound
+		}
+		return nil, err
+	}
+	return rev, nil
+}
+
+// CheckPublisher fails when PublishScheduledBlogs is not running or has
+// missed two of its runs, for instance because the database is locked.
+func (service *BlogService) CheckPublisher() error {
+	return service.publisher.check("scheduled publisher")
+}
+
+// PublishScheduledBlogs publishes due scheduled blogs every interval until
+// ctx is cancelled. It is meant to run in its own goroutine.
+func (service *BlogService) PublishScheduledBlogs(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	service.publisher.start(interval)
+	defer service.publisher.stop()
+
+	for {
+		service.publisher.beat()
+		published, err := service.BlogStore.PublishDueBlogs(time.Now())
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to publish scheduled blogs", "error", err)
+		} else if published > 0 {
+			slog.InfoContext(ctx, "published scheduled blogs", "count", published)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ownedBlog loads a blog and checks that the user may modify it.
+func (service *BlogService) ownedBlog(id int, user *model.User) (*model.Blog, error) {
+	blog, err := service.BlogStore.GetBlog(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrBlogNotFound
+		}
+		return nil, err
+	}
+
+	if !owns(user, blog) {
+		return nil, ErrForbidden
+	}
+	return blog, nil
+}
+
+// owns reports whether user may modify blog.
+func owns(user *model.User, blog *model.Blog) bool {
+	return user.IsAdmin || (blog.AuthorID != 0 && blog.AuthorID == user.ID)
+}
+
+// normalizeTags lowercases and trims tag names, dropping blanks and
+// duplicates while keeping the original order.
+func normalizeTags(tags []string) ([]string, error) {
+	normalized := []string{}
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if utf8.RuneCountInString(tag) > maxTagLength {
+			return nil, ErrInvalidTags
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+
+	if len(normalized) > maxTags {
+		return nil, ErrInvalidTags
+	}
+	return normalized, nil
 }
 
-func (service *BlogService) GetBlog(id int) (*model.Blog, error) {
-	return service.BlogRepo.GetBlog(id)
+// validateBlog checks what every blog needs, before create and update. The
+// status and format are checked by applyStatus and renderHTML.
+func validateBlog(blog *model.Blog) error {
+	if strings.TrimSpace(blog.Title) == "" || strings.TrimSpace(blog.Content) == "" {
+		return ErrInvalidBlog
+	}
+	return nil
 }
 
-func (service *BlogService) GetAllBlogs() ([]model.Blog, error) {
-	return service.BlogRepo.GetAllBlogs()
+// tagChanges returns the tags added and removed going from one set of tags
+// to another, by name.
+func tagChanges(from, to []string) (added, removed []string) {
+	added, removed = []string{}, []string{}
+	for _, tag := range to {
+		if !slices.Contains(from, tag) {
+			added = append(added, tag)
+		}
+	}
+	for _, tag := range from {
+		if !slices.Contains(to, tag) {
+			removed = append(removed, tag)
+		}
+	}
+	slices.Sort(added)
+	slices.Sort(removed)
+	return added, removed
 }
 
-func (service *BlogService) UpdateBlog(blog *model.Blog) (*model.Blog, error) {
-	return service.BlogRepo.UpdateBlog(blog)
+// applyStatus validates blog.Status and fills in the publish time of blogs
+// published without one.
+func applyStatus(blog *model.Blog, now time.Time) error {
+	switch blog.Status {
+	case model.StatusPublished:
+		if blog.PublishAt == nil {
+			blog.PublishAt = &now
+		}
+	case model.StatusScheduled:
+		if blog.PublishAt == nil || !blog.PublishAt.After(now) {
+			return ErrInvalidSchedule
+		}
+	case model.StatusDraft, model.StatusArchived:
+	default:
+		return ErrInvalidStatus
+	}
+	return nil
 }
 
-func (service *BlogService) DeleteBlog(id int) error {
-	return service.BlogRepo.DeleteBlog(id)
+// canView reports whether the viewer may see the blog: anyone can see
+// published blogs, only the author and admins can see the others.
+func canView(blog *model.Blog, viewer *model.User) bool {
+	if blog.Status == model.StatusPublished {
+		return true
+	}
+	return viewer != nil && (viewer.IsAdmin || (blog.AuthorID != 0 && blog.AuthorID == viewer.ID))
 }
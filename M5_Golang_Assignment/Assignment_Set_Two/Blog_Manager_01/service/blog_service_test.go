@@ -0,0 +1,47 @@
+package service
+
+import (
+	"blogmanager/model"
+	"blogmanager/repository"
+	"errors"
+	"testing"
+)
+
+func TestBlogValidation(t *testing.T) {
+	author := &model.User{ID: 1, Username: "alice"}
+	tests := []struct {
+		name string
+		blog model.Blog
+		want error
+	}{
+		{"valid", model.Blog{Title: "Title", Content: "Content"}, nil},
+		{"empty", model.Blog{}, ErrInvalidBlog},
+		{"no title", model.Blog{Content: "Content"}, ErrInvalidBlog},
+		{"blank title", model.Blog{Title: " \t", Content: "Content"}, ErrInvalidBlog},
+		{"no content", model.Blog{Title: "Title"}, ErrInvalidBlog},
+		{"blank content", model.Blog{Title: "Title", Content: "\n\n"}, ErrInvalidBlog},
+		{"invalid status", model.Blog{Title: "Title", Content: "Content", Status: "hidden"}, ErrInvalidStatus},
+		{"invalid format", model.Blog{Title: "Title", Content: "Content", Format: "rtf"}, ErrInvalidFormat},
+		{"too many tags", model.Blog{Title: "Title", Content: "Content",
+			Tags: []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k"}}, ErrInvalidTags},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewBlogService(repository.NewMemoryBlogStore())
+			blog := tt.blog
+			if _, err := service.CreateBlog(&blog, author); !errors.Is(err, tt.want) {
+				t.Errorf("CreateBlog = %v, want %v", err, tt.want)
+			}
+
+			existing, err := service.CreateBlog(&model.Blog{Title: "Existing", Content: "Existing"}, author)
+			if err != nil {
+				t.Fatal(err)
+			}
+			update := tt.blog
+			update.ID = existing.ID
+			if _, err := service.UpdateBlog(&update, author); !errors.Is(err, tt.want) {
+				t.Errorf("UpdateBlog = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
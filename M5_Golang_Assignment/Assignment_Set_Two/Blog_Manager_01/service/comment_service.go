@@ -0,0 +1,138 @@
+package service
+
+import (
+	"blogmanager/model"
+	"blogmanager/repository"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+var (
+	ErrCommentNotFound  = errors.New("comment not found")
+	ErrCommentForbidden = errors.New("only the author or an admin can modify this comment")
+	ErrEmptyComment     = errors.New("comment content is required")
+	ErrInvalidParent    = errors.New("parent must be a top-level comment on the same blog")
+)
+
+type CommentService struct {
+	CommentRepo *repository.CommentRepository
+	BlogStore   repository.BlogStore
+}
+
+func NewCommentService(commentRepo *repository.CommentRepository, blogStore repository.BlogStore) *CommentService {
+	return &CommentService{CommentRepo: commentRepo, BlogStore: blogStore}
+}
+
+// GetComments returns the top-level comments of a blog with their replies
+// nested underneath.
+func (service *CommentService) GetComments(blogID int, user *model.User) ([]model.Comment, error) {
+	if err := service.checkBlogVisible(blogID, user); err != nil {
+		return nil, err
+	}
+
+	comments, err := service.CommentRepo.GetCommentsByBlog(blogID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Comments are ordered by id, so every parent precedes its replies
+	threads := []model.Comment{}
+	index := make(map[int]int)
+	for _, comment := range comments {
+		if comment.ParentID == nil {
+			index[comment.ID] = len(threads)
+			threads = append(threads, comment)
+			continue
+		}
+		if i, ok := index[*comment.ParentID]; ok {
+			threads[i].Replies = append(threads[i].Replies, comment)
+		}
+	}
+	return threads, nil
+}
+
+// CreateComment adds a comment, or a reply when ParentID is set, by the user.
+func (service *CommentService) CreateComment(comment *model.Comment, user *model.User) (*model.Comment, error) {
+	comment.Content = strings.TrimSpace(comment.Content)
+	if comment.Content == "" {
+		return nil, ErrEmptyComment
+	}
+	if err := service.checkBlogVisible(comment.BlogID, user); err != nil {
+		return nil, err
+	}
+
+	if comment.ParentID != nil {
+		parent, err := service.CommentRepo.GetComment(*comment.ParentID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, ErrInvalidParent
+			}
+			return nil, err
+		}
+		if parent.BlogID != comment.BlogID || parent.ParentID != nil {
+			return nil, ErrInvalidParent
+		}
+	}
+
+	comment.AuthorID = user.ID
+	comment.Author = user.Username
+	return service.CommentRepo.CreateComment(comment)
+}
+
+// UpdateComment replaces the content of a comment the user wrote.
+func (service *CommentService) UpdateComment(id int, content string, user *model.User) (*model.Comment, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil, ErrEmptyComment
+	}
+
+	comment, err := service.ownedComment(id, user)
+	if err != nil {
+		return nil, err
+	}
+
+	comment.Content = content
+	return service.CommentRepo.UpdateComment(comment)
+}
+
+// DeleteComment removes a comment the user wrote together with its replies.
+func (service *CommentService) DeleteComment(id int, user *model.User) error {
+	if _, err := service.ownedComment(id, user); err != nil {
+		return err
+	}
+	return service.CommentRepo.DeleteComment(id)
+}
+
+// checkBlogVisible fails with ErrBlogNotFound unless the blog exists and the
+// user is allowed to see it.
+func (service *CommentService) checkBlogVisible(blogID int, user *model.User) error {
+	blog, err := service.BlogStore.GetBlog(blogID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrBlogNotFound
+		}
+		return err
+	}
+
+	if !canView(blog, user) {
+		return ErrBlogNotFound
+	}
+	return nil
+}
+
+// ownedComment loads a comment and checks that the user may modify it.
+func (service *CommentService) ownedComment(id int, user *model.User) (*model.Comment, error) {
+	comment, err := service.CommentRepo.GetComment(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrCommentNotFound
+		}
+		return nil, err
+	}
+
+	if !user.IsAdmin && comment.AuthorID != user.ID {
+		return nil, ErrCommentForbidden
+	}
+	return comment, nil
+}
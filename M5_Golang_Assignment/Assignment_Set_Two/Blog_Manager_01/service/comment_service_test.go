@@ -0,0 +1,147 @@
+package service
+
+import (
+	"blogmanager/model"
+	"blogmanager/repository"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type commentFixture struct {
+	installation
+	Comments *CommentService
+	Alice    *model.User
+	Bob      *model.User
+	Blog     *model.Blog
+}
+
+func newCommentFixture(t *testing.T) commentFixture {
+	t.Helper()
+	inst := newInstallation(t)
+	commentRepo := repository.NewCommentRepository(inst.Blogs.BlogStore.(*repository.BlogRepository).DB, repository.SQLite)
+	f := commentFixture{
+		installation: inst,
+		Comments:     NewCommentService(commentRepo, inst.Blogs.BlogStore),
+		Alice:        inst.user(t, "alice"),
+		Bob:          inst.user(t, "bob"),
+	}
+	f.Blog = f.blog(t, "Commented")
+	return f
+}
+
+func (f commentFixture) blog(t *testing.T, title string) *model.Blog {
+	t.Helper()
+	blog, err := f.Blogs.CreateBlog(&model.Blog{Title: title, Content: "Content"}, f.Alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return blog
+}
+
+func (f commentFixture) comment(t *testing.T, blogID int, parentID *int, user *model.User) *model.Comment {
+	t.Helper()
+	comment, err := f.Comments.CreateComment(&model.Comment{BlogID: blogID, ParentID: parentID, Content: "Comment"}, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return comment
+}
+
+func TestCommentThreads(t *testing.T) {
+	f := newCommentFixture(t)
+	top := f.comment(t, f.Blog.ID, nil, f.Bob)
+	reply := f.comment(t, f.Blog.ID, &top.ID, f.Alice)
+	if top.CreatedAt.IsZero() || !top.UpdatedAt.Equal(top.CreatedAt) {
+		t.Errorf("new comment timestamps: created %v, updated %v", top.CreatedAt, top.UpdatedAt)
+	}
+
+	other := f.blog(t, "Other")
+	missing := 9999
+	tests := []struct {
+		name     string
+		blogID   int
+		parentID *int
+		content  string
+		want     error
+	}{
+		{"reply to a reply", f.Blog.ID, &reply.ID, "Comment", ErrInvalidParent},
+		{"parent on another blog", other.ID, &top.ID, "Comment", ErrInvalidParent},
+		{"missing parent", f.Blog.ID, &missing, "Comment", ErrInvalidParent},
+		{"empty", f.Blog.ID, nil, " \n", ErrEmptyComment},
+		{"missing blog", 9999, nil, "Comment", ErrBlogNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := f.Comments.CreateComment(&model.Comment{BlogID: tt.blogID, ParentID: tt.parentID, Content: tt.content}, f.Bob)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("CreateComment = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	threads, err := f.Comments.GetComments(f.Blog.ID, f.Bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(threads) != 1 || threads[0].ID != top.ID || len(threads[0].Replies) != 1 || threads[0].Replies[0].ID != reply.ID {
+		t.Errorf("threads = %+v, want the comment with its one reply", threads)
+	}
+	if !threads[0].CreatedAt.Equal(top.CreatedAt) {
+		t.Errorf("stored created_at = %v, want %v", threads[0].CreatedAt, top.CreatedAt)
+	}
+}
+
+func TestOnlyAuthorsAndAdminsModifyComments(t *testing.T) {
+	f := newCommentFixture(t)
+	comment := f.comment(t, f.Blog.ID, nil, f.Bob)
+
+	if _, err := f.Comments.UpdateComment(comment.ID, "Changed", f.Alice); !errors.Is(err, ErrCommentForbidden) {
+		t.Errorf("UpdateComment by another user = %v, want %v", err, ErrCommentForbidden)
+	}
+	if err := f.Comments.DeleteComment(comment.ID, f.Alice); !errors.Is(err, ErrCommentForbidden) {
+		t.Errorf("DeleteComment by another user = %v, want %v", err, ErrCommentForbidden)
+	}
+
+	updated, err := f.Comments.UpdateComment(comment.ID, "Changed", f.Bob)
+	if err != nil || updated.Content != "Changed" || updated.UpdatedAt.Before(updated.CreatedAt) {
+		t.Errorf("UpdateComment by its author = %+v, %v", updated, err)
+	}
+	admin := &model.User{ID: 99, Username: "admin", IsAdmin: true}
+	if err := f.Comments.DeleteComment(comment.ID, admin); err != nil {
+		t.Errorf("DeleteComment by an admin = %v", err)
+	}
+	if err := f.Comments.DeleteComment(comment.ID, f.Bob); !errors.Is(err, ErrCommentNotFound) {
+		t.Errorf("DeleteComment of a deleted comment = %v, want %v", err, ErrCommentNotFound)
+	}
+}
+
+func TestCommentsGoWithTheirBlog(t *testing.T) {
+	f := newCommentFixture(t)
+	top := f.comment(t, f.Blog.ID, nil, f.Bob)
+	f.comment(t, f.Blog.ID, &top.ID, f.Alice)
+	other := f.blog(t, "Other")
+	kept := f.comment(t, other.ID, nil, f.Bob)
+
+	// Comments of trashed blogs stay until the blog is purged
+	if err := f.Blogs.DeleteBlog(f.Blog.ID, 0, f.Alice); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Comments.GetComments(f.Blog.ID, f.Alice); !errors.Is(err, ErrBlogNotFound) {
+		t.Errorf("GetComments of a trashed blog = %v, want %v", err, ErrBlogNotFound)
+	}
+	if _, err := f.Comments.CommentRepo.GetComment(top.ID); err != nil {
+		t.Errorf("comment of a trashed blog: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	f.Blogs.PurgeTrash(ctx, time.Hour, -time.Second)
+	if comments, err := f.Comments.CommentRepo.GetCommentsByBlog(f.Blog.ID); err != nil || len(comments) != 0 {
+		t.Errorf("comments of a purged blog = %+v, %v, want none", comments, err)
+	}
+	if _, err := f.Comments.CommentRepo.GetComment(kept.ID); err != nil {
+		t.Errorf("comment of another blog: %v", err)
+	}
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+)
+
+const diffContext = 3
+
+type diffOp byte
+
+const (
+	opEqual  diffOp = ' '
+	opDelete diffOp = '-'
+	opInsert diffOp = '+'
+)
+
+type diffLine struct {
+	op   diffOp
+	text string
+}
+
+// unifiedDiff returns a line-based unified diff turning from into to, with
+// three lines of context around each change. It is empty when the texts have
+// the same lines.
+func unifiedDiff(fromName, toName, from, to string) string {
+	lines := diffLines(splitLines(from), splitLines(to))
+
+	var sb strings.Builder
+	for _, h := range hunks(lines) {
+		if sb.Len() == 0 {
+			fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+		}
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(h.fromStart, h.fromCount), hunkRange(h.toStart, h.toCount))
+		for _, line := range lines[h.first:h.last] {
+			sb.WriteByte(byte(line.op))
+			sb.WriteString(line.text)
+			sb.WriteByte('\n')
+		}
+	}
+	return sb.String()
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffLines computes a shortest edit script from a to b with Myers'
+// algorithm.
+func diffLines(a, b []string) []diffLine {
+	n, m := len(a), len(b)
+	offset := n + m
+	v := make([]int, 2*offset+2)
+	var trace [][]int
+
+search:
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// Walk the trace backwards to recover the edits, then reverse them
+	var script []diffLine
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			script = append(script, diffLine{opEqual, a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				script = append(script, diffLine{opInsert, b[y-1]})
+			} else {
+				script = append(script, diffLine{opDelete, a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(script)-1; i < j; i, j = i+1, j-1 {
+		script[i], script[j] = script[j], script[i]
+	}
+	return script
+}
+
+type hunk struct {
+	first, last        int // range of lines in the edit script
+	fromStart, toStart int // zero-based first line in each text
+	fromCount, toCount int
+}
+
+// hunks groups the changes of an edit script, merging changes separated by
+// at most twice the context length.
+func hunks(lines []diffLine) []hunk {
+	var result []hunk
+	fromLine, toLine := 0, 0
+	var current *hunk
+	lastChange := -1
+
+	for i, line := range lines {
+		if line.op != opEqual {
+			if current == nil || i-lastChange-1 > 2*diffContext {
+				if current != nil {
+					result = append(result, closeHunk(*current, lines, lastChange))
+				}
+				first := max(0, i-diffContext)
+				current = &hunk{first: first, fromStart: fromLine - (i - first), toStart: toLine - (i - first)}
+			}
+			lastChange = i
+		}
+
+		if line.op != opInsert {
+			fromLine++
+		}
+		if line.op != opDelete {
+			toLine++
+		}
+	}
+	if current != nil {
+		result = append(result, closeHunk(*current, lines, lastChange))
+	}
+	return result
+}
+
+func closeHunk(h hunk, lines []diffLine, lastChange int) hunk {
+	h.last = min(len(lines), lastChange+diffContext+1)
+	for _, line := range lines[h.first:h.last] {
+		if line.op != opInsert {
+			h.fromCount++
+		}
+		if line.op != opDelete {
+			h.toCount++
+		}
+	}
+	return h
+}
+
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	default:
+		return fmt.Sprintf("%d,%d", start+1, count)
+	}
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"blogmanager/model"
+	"blogmanager/repository"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	lines := func(s ...string) string { return strings.Join(s, "\n") + "\n" }
+	tests := []struct {
+		name     string
+		from, to string
+		want     string
+	}{
+		{"identical", "a\nb\n", "a\nb\n", ""},
+		{"both empty", "", "", ""},
+		{"trailing newline only", "a\nb", "a\nb\n", ""},
+		{"from empty", "", "a\nb\n", lines("--- old", "+++ new", "@@ -0,0 +1,2 @@", "+a", "+b")},
+		{"to empty", "a\n", "", lines("--- old", "+++ new", "@@ -1 +0,0 @@", "-a")},
+		{
+			"change with context",
+			lines("1", "2", "3", "4", "5", "6", "7", "8", "9"),
+			lines("1", "2", "3", "4", "five", "6", "7", "8", "9"),
+			lines("--- old", "+++ new", "@@ -2,7 +2,7 @@", " 2", " 3", " 4", "-5", "+five", " 6", " 7", " 8"),
+		},
+		{
+			"separate hunks",
+			lines("a", "1", "2", "3", "4", "5", "6", "7", "8", "b"),
+			lines("A", "1", "2", "3", "4", "5", "6", "7", "8", "B"),
+			lines("--- old", "+++ new",
+				"@@ -1,4 +1,4 @@", "-a", "+A", " 1", " 2", " 3",
+				"@@ -7,4 +7,4 @@", " 6", " 7", " 8", "-b", "+B"),
+		},
+		{
+			"close changes share a hunk",
+			lines("a", "1", "2", "3", "4", "5", "6", "b"),
+			lines("A", "1", "2", "3", "4", "5", "6", "B"),
+			lines("--- old", "+++ new", "@@ -1,8 +1,8 @@", "-a", "+A", " 1", " 2", " 3", " 4", " 5", " 6", "-b", "+B"),
+		},
+		{
+			"insertion",
+			lines("a", "c"),
+			lines("a", "b", "c"),
+			lines("--- old", "+++ new", "@@ -1,2 +1,3 @@", " a", "+b", " c"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unifiedDiff("old", "new", tt.from, tt.to); got != tt.want {
+				t.Errorf("unifiedDiff =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffRevision(t *testing.T) {
+	author := &model.User{ID: 1, Username: "alice"}
+	tests := []struct {
+		name   string
+		update model.Blog
+		want   model.RevisionDiff
+	}{
+		{
+			name:   "content",
+			update: model.Blog{Title: "Title", Content: "Line one\nLine 2\n"},
+			want:   model.RevisionDiff{Diff: "--- revision 1\n+++ current\n@@ -1,2 +1,2 @@\n Line one\n-Line two\n+Line 2\n"},
+		},
+		{
+			name:   "title",
+			update: model.Blog{Title: "New title", Content: "Line one\nLine two\n"},
+			want:   model.RevisionDiff{Title: &model.FieldChange{From: "Title", To: "New title"}},
+		},
+		{
+			name:   "status",
+			update: model.Blog{Title: "Title", Content: "Line one\nLine two\n", Status: model.StatusDraft},
+			want:   model.RevisionDiff{Status: &model.FieldChange{From: model.StatusPublished, To: model.StatusDraft}},
+		},
+		{
+			name:   "tags",
+			update: model.Blog{Title: "Title", Content: "Line one\nLine two\n", Tags: []string{"web", "api", "go"}},
+			want:   model.RevisionDiff{Tags: &model.TagsChange{Added: []string{"api", "web"}, Removed: []string{"news"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewBlogService(repository.NewMemoryBlogStore())
+			blog, err := service.CreateBlog(&model.Blog{Title: "Title", Content: "Line one\nLine two\n", Tags: []string{"news", "go"}}, author)
+			if err != nil {
+				t.Fatal(err)
+			}
+			update := tt.update
+			update.ID = blog.ID
+			if _, err := service.UpdateBlog(&update, author); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := service.DiffRevision(blog.ID, 1, author)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.want.BlogID, tt.want.Revision = blog.ID, 1
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("DiffRevision = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
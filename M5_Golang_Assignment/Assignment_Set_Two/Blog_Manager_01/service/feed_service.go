@@ -0,0 +1,255 @@
+package service
+
+import (
+	"blogmanager/model"
+	"blogmanager/repository"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+const feedSize = 20
+
+var ErrAuthorNotFound = errors.New("author not found")
+
+// Feed is a rendered RSS or Atom document with the validators needed to
+// answer conditional GETs.
+type Feed struct {
+	Body         []byte
+	ETag         string
+	LastModified time.Time
+}
+
+type FeedService struct {
+	BlogService *BlogService
+	UserRepo    *repository.UserRepository
+}
+
+func NewFeedService(blogService *BlogService, userRepo *repository.UserRepository) *FeedService {
+	return &FeedService{BlogService: blogService, UserRepo: userRepo}
+}
+
+type rssDocument struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	DC      string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	SelfLink      atomLink  `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        string   `xml:"guid"`
+	Author      string   `xml:"dc:creator,omitempty"`
+	Categories  []string `xml:"category"`
+	PubDate     string   `xml:"pubDate"`
+	Description string   `xml:"description"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Links   []atomLink  `xml:"link"`
+	Updated string      `xml:"updated"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Link       atomLink       `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Author     atomAuthor     `xml:"author"`
+	Categories []atomCategory `xml:"category"`
+	Content    atomContent    `xml:"content"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// RSS builds an RSS 2.0 feed of the latest published blogs. baseURL is the
+// scheme and host the feed is served from.
+func (service *FeedService) RSS(baseURL string) (*Feed, error) {
+	blogs, updated, err := service.latestBlogs("")
+	if err != nil {
+		return nil, err
+	}
+
+	channel := rssChannel{
+		Title:         "Blog Manager",
+		Link:          baseURL + "/",
+		Description:   "Latest published blogs",
+		SelfLink:      atomLink{Href: baseURL + "/feed.rss", Rel: "self", Type: "application/rss+xml"},
+		LastBuildDate: updated.Format(time.RFC1123Z),
+	}
+	for _, blog := range blogs {
+		link := blogURL(baseURL, blog.ID)
+		channel.Items = append(channel.Items, rssItem{
+			Title:       blog.Title,
+			Link:        link,
+			GUID:        link,
+			Author:      blog.Author,
+			Categories:  blog.Tags,
+			PubDate:     publishedAt(&blog).Format(time.RFC1123Z),
+			Description: blog.HTML,
+		})
+	}
+
+	doc := rssDocument{
+		Version: "2.0",
+		Atom:    "http://www.w3.org/2005/Atom",
+		DC:      "http://purl.org/dc/elements/1.1/",
+		Channel: channel,
+	}
+	body, err := marshalFeed(doc)
+	if err != nil {
+		return nil, err
+	}
+	return newFeed(body, updated), nil
+}
+
+// Atom builds an Atom feed of the latest published blogs, limited to one
+// author when author is not empty.
+func (service *FeedService) Atom(baseURL, author string) (*Feed, error) {
+	selfURL := baseURL + "/feed.atom"
+	title := "Blog Manager"
+	if author != "" {
+		if _, err := service.UserRepo.GetUserByUsername(author); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, ErrAuthorNotFound
+			}
+			return nil, err
+		}
+		selfURL = baseURL + "/authors/" + url.PathEscape(author) + "/feed.atom"
+		title = "Blog Manager: " + author
+	}
+
+	blogs, updated, err := service.latestBlogs(author)
+	if err != nil {
+		return nil, err
+	}
+
+	feed := atomFeed{
+		Title: title,
+		ID:    selfURL,
+		Links: []atomLink{
+			{Href: selfURL, Rel: "self", Type: "application/atom+xml"},
+			{Href: baseURL + "/", Rel: "alternate"},
+		},
+		Updated: updated.Format(time.RFC3339),
+	}
+	for _, blog := range blogs {
+		link := blogURL(baseURL, blog.ID)
+		entry := atomEntry{
+			Title:     blog.Title,
+			ID:        link,
+			Link:      atomLink{Href: link, Rel: "alternate"},
+			Published: publishedAt(&blog).Format(time.RFC3339),
+			Updated:   blog.UpdatedAt.UTC().Format(time.RFC3339),
+			Author:    atomAuthor{Name: blog.Author},
+			Content:   atomContent{Type: "html", Body: blog.HTML},
+		}
+		for _, tag := range blog.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	body, err := marshalFeed(feed)
+	if err != nil {
+		return nil, err
+	}
+	return newFeed(body, updated), nil
+}
+
+// latestBlogs returns the newest published blogs, rendered, and the last
+// time the feed could have changed. That is when any blog of author last
+// changed rather than the newest of the listed ones, which would miss blogs
+// leaving the feed by being trashed, unpublished or pushed out.
+func (service *FeedService) latestBlogs(author string) ([]model.Blog, time.Time, error) {
+	page, err := service.BlogService.GetAllBlogs(model.BlogListOptions{
+		Limit:  feedSize,
+		Author: author,
+		Status: model.StatusPublished,
+	})
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	for i := range page.Data {
+		if err := service.BlogService.RenderBlog(&page.Data[i]); err != nil {
+			return nil, time.Time{}, err
+		}
+	}
+
+	updated, err := service.BlogService.BlogStore.LastChanged(author)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if updated.IsZero() {
+		updated = time.Unix(0, 0)
+	}
+	return page.Data, updated.UTC(), nil
+}
+
+func marshalFeed(doc any) ([]byte, error) {
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+func newFeed(body []byte, lastModified time.Time) *Feed {
+	sum := sha256.Sum256(body)
+	return &Feed{
+		Body:         body,
+		ETag:         `"` + hex.EncodeToString(sum[:16]) + `"`,
+		LastModified: lastModified.Truncate(time.Second),
+	}
+}
+
+func blogURL(baseURL string, id int) string {
+	return fmt.Sprintf("%s/api/blog/%d", baseURL, id)
+}
+
+// publishedAt is when the blog went live, falling back to its creation time
+// for blogs published before publish times were recorded.
+func publishedAt(blog *model.Blog) time.Time {
+	if blog.PublishAt != nil {
+		return blog.PublishAt.UTC()
+	}
+	return blog.CreatedAt.UTC()
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// heartbeat records when a background worker last ran so that readiness
+// checks can tell whether it is still alive.
+type heartbeat struct {
+	// last is the time of the last run in Unix nanoseconds, or 0 while the
+	// worker is not running.
+	last     atomic.Int64
+	interval atomic.Int64
+}
+
+func (h *heartbeat) start(interval time.Duration) {
+	h.interval.Store(int64(interval))
+}
+
+func (h *heartbeat) beat() {
+	h.last.Store(time.Now().UnixNano())
+}
+
+func (h *heartbeat) stop() {
+	h.last.Store(0)
+}
+
+// check fails when the worker is not running or has missed two of its runs.
+func (h *heartbeat) check(worker string) error {
+	last := h.last.Load()
+	if last == 0 {
+		return fmt.Errorf("%s is not running", worker)
+	}
+	if since := time.Since(time.Unix(0, last)); since > 2*time.Duration(h.interval.Load()) {
+		return fmt.Errorf("%s last ran %s ago", worker, since.Round(time.Second))
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"blogmanager/model"
+	"blogmanager/repository"
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+)
+
+// Patch media types accepted by PatchBlog.
+const (
+	MergePatch = "application/merge-patch+json"
+	JSONPatch  = "application/json-patch+json"
+)
+
+var (
+	ErrUnsupportedPatch = fmt.Errorf("patches must be %s or %s", MergePatch, JSONPatch)
+	ErrInvalidPatch     = errors.New("invalid patch")
+	ErrPatchTestFailed  = errors.New("a test operation of the patch failed")
+)
+
+// maxPatchAttempts bounds how often PatchBlog reapplies a patch when another
+// update gets in first.
+const maxPatchAttempts = 3
+
+// patchableBlog is the document patches apply to, holding the fields of a
+// blog its author may change.
+type patchableBlog struct {
+	Title     string     `json:"title"`
+	Content   string     `json:"content"`
+	Tags      []string   `json:"tags"`
+	Status    string     `json:"status"`
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+	Format    string     `json:"format"`
+}
+
+func (blog patchableBlog) equal(other patchableBlog) bool {
+	return blog.Title == other.Title && blog.Content == other.Content && slices.Equal(blog.Tags, other.Tags) &&
+		blog.Status == other.Status && sameTime(blog.PublishAt, other.PublishAt) && blog.Format == other.Format
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// PatchBlog applies an RFC 7396 merge patch or an RFC 6902 JSON patch to a
+// blog the user owns, then validates and saves the result like UpdateBlog.
+// Only the fields the patch changed are taken from it, and the blog is only
+// written if it is still at the version the patch was applied to. Without an
+// expected version, a patch that loses that race is reapplied to the newer
+// blog; with one, the version must match the stored blog. A patch that leaves
+// the blog as it was is not saved.
+func (service *BlogService) PatchBlog(id, version int, mediaType string, patch []byte, user *model.User) (*model.Blog, error) {
+	for attempt := 1; ; attempt++ {
+		existing, err := service.ownedBlog(id, user)
+		if err != nil {
+			return nil, err
+		}
+		if version != 0 && version != existing.Version {
+			return nil, repository.ErrVersionConflict
+		}
+
+		original := patchableBlog{
+			Title:     existing.Title,
+			Content:   existing.Content,
+			Tags:      existing.Tags,
+			Status:    existing.Status,
+			PublishAt: existing.PublishAt,
+			Format:    existing.Format,
+		}
+		patched, err := applyPatch(original, mediaType, patch)
+		if err != nil {
+			return nil, err
+		}
+		// A removed status or format keeps the stored one, as with UpdateBlog,
+		// and a patch that changes nothing writes nothing
+		patched.Status = cmp.Or(patched.Status, original.Status)
+		patched.Format = cmp.Or(patched.Format, original.Format)
+		if patched.equal(original) {
+			return existing, nil
+		}
+
+		update := &model.Blog{
+			ID:      id,
+			Title:   patched.Title,
+			Content: patched.Content,
+			Format:  patched.Format,
+			Version: existing.Version,
+		}
+		if !slices.Equal(patched.Tags, original.Tags) {
+			update.Tags = patched.Tags
+			if update.Tags == nil {
+				update.Tags = []string{}
+			}
+		}
+		if patched.Status != original.Status || !sameTime(patched.PublishAt, original.PublishAt) {
+			update.Status = patched.Status
+			update.PublishAt = patched.PublishAt
+		}
+
+		updated, err := service.UpdateBlog(update, user)
+		if errors.Is(err, repository.ErrVersionConflict) && version == 0 && attempt < maxPatchAttempts {
+			continue
+		}
+		return updated, err
+	}
+}
+
+// applyPatch returns blog with patch applied. The result may not add fields,
+// so a patch touching read-only fields such as author is rejected.
+func applyPatch(blog patchableBlog, mediaType string, patch []byte) (*patchableBlog, error) {
+	doc, err := json.Marshal(blog)
+	if err != nil {
+		return nil, err
+	}
+
+	var patchedDoc []byte
+	switch mediaType {
+	case MergePatch:
+		patchedDoc, err = jsonpatch.MergePatch(doc, patch)
+	case JSONPatch:
+		var ops jsonpatch.Patch
+		if ops, err = jsonpatch.DecodePatch(patch); err == nil {
+			patchedDoc, err = ops.Apply(doc)
+		}
+	default:
+		return nil, ErrUnsupportedPatch
+	}
+	if errors.Is(err, jsonpatch.ErrTestFailed) {
+		return nil, ErrPatchTestFailed
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(patchedDoc))
+	decoder.DisallowUnknownFields()
+	var patched patchableBlog
+	if err := decoder.Decode(&patched); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return nil, fmt.Errorf("%w: %s must be of type %s", ErrInvalidPatch, typeErr.Field, typeErr.Type)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+	return &patched, nil
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"blogmanager/model"
+	"blogmanager/repository"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestPatchBlog(t *testing.T) {
+	author := &model.User{ID: 1, Username: "alice"}
+	tests := []struct {
+		name         string
+		mediaType    string
+		patch        string
+		user         *model.User
+		version      int
+		want         error
+		wantVersion  int
+		wantTitle    string
+		wantContent  string
+		wantTags     []string
+		wantStatus   string
+		wantFormat   string
+		wantRevision bool
+	}{
+		{
+			name: "merge patch", mediaType: MergePatch, patch: `{"title":"New title"}`,
+			wantVersion: 2, wantTitle: "New title", wantRevision: true,
+		},
+		{
+			name: "json patch", mediaType: JSONPatch, patch: `[{"op":"replace","path":"/content","value":"New content"}]`,
+			wantVersion: 2, wantContent: "New content", wantRevision: true,
+		},
+		{
+			name: "tags are normalized", mediaType: MergePatch, patch: `{"tags":[" Go ","go","Web"]}`,
+			wantVersion: 2, wantTags: []string{"go", "web"}, wantRevision: true,
+		},
+		{
+			name: "null tags clear them", mediaType: MergePatch, patch: `{"tags":null}`,
+			wantVersion: 2, wantTags: []string{}, wantRevision: true,
+		},
+		{
+			name: "status change", mediaType: MergePatch, patch: `{"status":"draft"}`,
+			wantVersion: 2, wantStatus: model.StatusDraft, wantRevision: true,
+		},
+		{name: "null status is a no-op", mediaType: MergePatch, patch: `{"status":null}`, wantVersion: 1},
+		{name: "null format is a no-op", mediaType: MergePatch, patch: `{"format":null}`, wantVersion: 1},
+		{name: "same title is a no-op", mediaType: MergePatch, patch: `{"title":"Title"}`, wantVersion: 1},
+		{name: "empty patch is a no-op", mediaType: MergePatch, patch: `{}`, wantVersion: 1},
+		{name: "null title", mediaType: MergePatch, patch: `{"title":null,"content":""}`, want: ErrInvalidBlog},
+		{name: "blank title", mediaType: MergePatch, patch: `{"title":"  "}`, want: ErrInvalidBlog},
+		{name: "removed content", mediaType: JSONPatch, patch: `[{"op":"remove","path":"/content"}]`, want: ErrInvalidBlog},
+		{name: "invalid status", mediaType: MergePatch, patch: `{"status":"hidden"}`, want: ErrInvalidStatus},
+		{name: "invalid format", mediaType: MergePatch, patch: `{"format":"rtf"}`, want: ErrInvalidFormat},
+		{name: "schedule in the past", mediaType: MergePatch, patch: `{"status":"scheduled","publish_at":"2000-01-01T00:00:00Z"}`, want: ErrInvalidSchedule},
+		{name: "read-only field", mediaType: MergePatch, patch: `{"author":"mallory"}`, want: ErrInvalidPatch},
+		{name: "wrong type", mediaType: MergePatch, patch: `{"title":5}`, want: ErrInvalidPatch},
+		{name: "malformed", mediaType: JSONPatch, patch: `{"op":"replace"}`, want: ErrInvalidPatch},
+		{name: "failed test", mediaType: JSONPatch, patch: `[{"op":"test","path":"/title","value":"Other"}]`, want: ErrPatchTestFailed},
+		{name: "unsupported media type", mediaType: "application/json", patch: `{}`, want: ErrUnsupportedPatch},
+		{name: "not the author", mediaType: MergePatch, patch: `{"title":"x"}`, user: &model.User{ID: 2}, want: ErrForbidden},
+		{name: "admin", mediaType: MergePatch, patch: `{"title":"By admin"}`, user: &model.User{ID: 3, IsAdmin: true},
+			wantVersion: 2, wantTitle: "By admin", wantRevision: true},
+		{name: "stale version", mediaType: MergePatch, patch: `{"title":"x"}`, version: 5, want: repository.ErrVersionConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := repository.NewMemoryBlogStore()
+			service := NewBlogService(store)
+			blog, err := service.CreateBlog(&model.Blog{Title: "Title", Content: "Content", Tags: []string{"go"}}, author)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			user := tt.user
+			if user == nil {
+				user = author
+			}
+			_, err = service.PatchBlog(blog.ID, tt.version, tt.mediaType, []byte(tt.patch), user)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("PatchBlog(%s) = %v, want %v", tt.patch, err, tt.want)
+			}
+
+			stored, err := store.GetBlog(blog.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			revisions, err := store.GetRevisions(blog.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want != nil {
+				if stored.Version != 1 || len(revisions) != 0 {
+					t.Errorf("failed patch left version %d and %d revisions", stored.Version, len(revisions))
+				}
+				return
+			}
+
+			if stored.Version != tt.wantVersion {
+				t.Errorf("version = %d, want %d", stored.Version, tt.wantVersion)
+			}
+			if got := len(revisions) > 0; got != tt.wantRevision {
+				t.Errorf("revision saved = %v, want %v", got, tt.wantRevision)
+			}
+			checks := []struct{ field, got, want, fallback string }{
+				{"title", stored.Title, tt.wantTitle, "Title"},
+				{"content", stored.Content, tt.wantContent, "Content"},
+				{"status", stored.Status, tt.wantStatus, model.StatusPublished},
+				{"format", stored.Format, tt.wantFormat, model.FormatMarkdown},
+			}
+			for _, check := range checks {
+				want := check.want
+				if want == "" {
+					want = check.fallback
+				}
+				if check.got != want {
+					t.Errorf("%s = %q, want %q", check.field, check.got, want)
+				}
+			}
+			wantTags := tt.wantTags
+			if wantTags == nil {
+				wantTags = []string{"go"}
+			}
+			if !slices.Equal(stored.Tags, wantTags) {
+				t.Errorf("tags = %v, want %v", stored.Tags, wantTags)
+			}
+		})
+	}
+}
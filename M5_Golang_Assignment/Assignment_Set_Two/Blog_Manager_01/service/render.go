@@ -0,0 +1,50 @@
+package service
+
+import (
+	"blogmanager/model"
+	"bytes"
+	"html"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+var (
+	markdown = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+	// sanitizer allows the formatting, links, images and tables user content
+	// needs and strips scripts, styles, event handlers and unsafe URLs.
+	sanitizer = bluemonday.UGCPolicy()
+)
+
+// renderHTML turns blog content into sanitized HTML according to its format.
+// HTML and Markdown output goes through the sanitizer since both may contain
+// raw markup; plain text is escaped and split into paragraphs.
+func renderHTML(format, content string) (string, error) {
+	switch format {
+	case model.FormatMarkdown:
+		var buf bytes.Buffer
+		if err := markdown.Convert([]byte(content), &buf); err != nil {
+			return "", err
+		}
+		return sanitizer.Sanitize(buf.String()), nil
+	case model.FormatHTML:
+		return sanitizer.Sanitize(content), nil
+	case model.FormatPlain:
+		var sb strings.Builder
+		for _, paragraph := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n\n") {
+			paragraph = strings.TrimSpace(paragraph)
+			if paragraph == "" {
+				continue
+			}
+			sb.WriteString("<p>")
+			sb.WriteString(strings.ReplaceAll(html.EscapeString(paragraph), "\n", "<br>\n"))
+			sb.WriteString("</p>\n")
+		}
+		return sb.String(), nil
+	default:
+		return "", ErrInvalidFormat
+	}
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"blogmanager/model"
+	"blogmanager/repository"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRenderHTMLSanitizes(t *testing.T) {
+	unsafe := []string{"<script", "alert(", "onerror", "javascript:", "<iframe", "<style"}
+	tests := []struct {
+		name, format, content string
+		// want are fragments the rendering must keep
+		want []string
+	}{
+		{"markdown", model.FormatMarkdown, "# Title\n\nSome **bold** text and a [link](https://example.com).",
+			[]string{"<h1>Title</h1>", "<strong>bold</strong>", `<a href="https://example.com" rel="nofollow">link</a>`}},
+		{"markdown script", model.FormatMarkdown, "Before\n\n<script>alert(1)</script>\n\nAfter",
+			[]string{"<p>Before</p>", "<p>After</p>"}},
+		{"markdown javascript link", model.FormatMarkdown, "[click](javascript:alert(1))",
+			[]string{"click"}},
+		{"markdown raw html block", model.FormatMarkdown, "<div onclick=\"alert(1)\">\n<iframe src=\"https://evil.example\"></iframe>\n</div>\n\nText",
+			[]string{"<p>Text</p>"}},
+		{"markdown inline event handler", model.FormatMarkdown, `Look <img src="x.png" onerror="alert(1)"> here`,
+			[]string{"Look", "here"}},
+		{"markdown table", model.FormatMarkdown, "| a | b |\n|---|---|\n| 1 | 2 |",
+			[]string{"<table>", "<td>1</td>"}},
+		{"html", model.FormatHTML, `<p>Hello <em>world</em></p>`,
+			[]string{"<p>Hello <em>world</em></p>"}},
+		{"html script", model.FormatHTML, `<p>Hi</p><script>alert(1)</script>`,
+			[]string{"<p>Hi</p>"}},
+		{"html event handler", model.FormatHTML, `<img src="https://example.com/x.png" onerror="alert(1)">`,
+			[]string{`<img src="https://example.com/x.png">`}},
+		{"html javascript link", model.FormatHTML, `<a href="javascript:alert(1)">click</a>`,
+			[]string{"click"}},
+		{"html style and iframe", model.FormatHTML, `<style>p{}</style><iframe src="https://evil.example"></iframe><p>Kept</p>`,
+			[]string{"<p>Kept</p>"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rendered, err := renderHTML(tt.format, tt.content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, fragment := range unsafe {
+				if strings.Contains(rendered, fragment) {
+					t.Errorf("rendering contains %q: %s", fragment, rendered)
+				}
+			}
+			for _, fragment := range tt.want {
+				if !strings.Contains(rendered, fragment) {
+					t.Errorf("rendering lacks %q: %s", fragment, rendered)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderPlainEscapes(t *testing.T) {
+	content := "<script>alert(1)</script> & **not bold**\nsecond line\r\n\r\n\n\n<b>next</b>"
+	rendered, err := renderHTML(model.FormatPlain, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "<p>&lt;script&gt;alert(1)&lt;/script&gt; &amp; **not bold**<br>\nsecond line</p>\n" +
+		"<p>&lt;b&gt;next&lt;/b&gt;</p>\n"
+	if rendered != want {
+		t.Errorf("renderHTML = %q, want %q", rendered, want)
+	}
+}
+
+func TestRenderHTMLRejectsUnknownFormat(t *testing.T) {
+	if _, err := renderHTML("rtf", "Content"); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("renderHTML = %v, want %v", err, ErrInvalidFormat)
+	}
+}
+
+// TestUpdateInvalidatesRenderedHTML checks that the rendering cached by
+// RenderBlog follows the content rather than outliving it.
+func TestUpdateInvalidatesRenderedHTML(t *testing.T) {
+	services := map[string]func(t *testing.T) (*BlogService, *model.User){
+		"memory": func(t *testing.T) (*BlogService, *model.User) {
+			return NewBlogService(repository.NewMemoryBlogStore()), &model.User{ID: 1, Username: "alice"}
+		},
+		"sqlite": func(t *testing.T) (*BlogService, *model.User) {
+			inst := newInstallation(t)
+			return inst.Blogs, inst.user(t, "alice")
+		},
+	}
+	for name, newService := range services {
+		t.Run(name, func(t *testing.T) {
+			service, author := newService(t)
+			blog, err := service.CreateBlog(&model.Blog{Title: "Title", Content: "First *draft*"}, author)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rendered := renderedBlog(t, service, blog.ID)
+			if !strings.Contains(rendered, "First <em>draft</em>") {
+				t.Fatalf("rendering = %q", rendered)
+			}
+
+			if _, err := service.UpdateBlog(&model.Blog{ID: blog.ID, Title: "Title", Content: "Second <b>take</b>",
+				Format: model.FormatPlain}, author); err != nil {
+				t.Fatal(err)
+			}
+			rendered = renderedBlog(t, service, blog.ID)
+			if rendered != "<p>Second &lt;b&gt;take&lt;/b&gt;</p>\n" {
+				t.Errorf("rendering after update = %q, want the new content as plain text", rendered)
+			}
+		})
+	}
+}
+
+func renderedBlog(t *testing.T, service *BlogService, id int) string {
+	t.Helper()
+	blog, err := service.BlogStore.GetBlog(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := service.RenderBlog(blog); err != nil {
+		t.Fatal(err)
+	}
+	return blog.HTML
+}
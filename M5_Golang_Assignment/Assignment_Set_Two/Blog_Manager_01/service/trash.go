@@ -0,0 +1,72 @@
+package service
+
+import (
+	"blogmanager/model"
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// GetTrash lists the trashed blogs the user could restore.
+func (service *BlogService) GetTrash(user *model.User) ([]model.Blog, error) {
+	return service.BlogStore.GetTrash(user)
+}
+
+// RestoreBlog takes a blog the user owns out of the trash.
+func (service *BlogService) RestoreBlog(id int, user *model.User) (*model.Blog, error) {
+	blog, err := service.BlogStore.GetTrashedBlog(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrBlogNotFound
+		}
+		return nil, err
+	}
+	if !owns(user, blog) {
+		return nil, ErrForbidden
+	}
+
+	if err := service.BlogStore.RestoreBlog(id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrBlogNotFound
+		}
+		return nil, err
+	}
+	return service.BlogStore.GetBlog(id)
+}
+
+// CheckPurger fails when PurgeTrash is not running or has missed two of its
+// runs.
+func (service *BlogService) CheckPurger() error {
+	return service.purger.check("trash purger")
+}
+
+// PurgeTrash permanently deletes the blogs that have been in the trash for
+// longer than retention, every interval until ctx is cancelled. It is meant
+// to run in its own goroutine.
+func (service *BlogService) PurgeTrash(ctx context.Context, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	service.purger.start(interval)
+	defer service.purger.stop()
+
+	for {
+		service.purger.beat()
+		purged, err := service.BlogStore.PurgeTrash(time.Now().Add(-retention))
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to purge trashed blogs", "error", err)
+		} else if purged > 0 {
+			slog.InfoContext(ctx, "purged trashed blogs", "count", purged)
+			if service.OnPurge != nil {
+				service.OnPurge(ctx)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"blogmanager/model"
+	"blogmanager/repository"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	minPasswordLength = 8  // characters
+	maxPasswordBytes  = 72 // bcrypt ignores anything past 72 bytes
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrUserNotFound       = errors.New("user not found")
+	ErrInvalidUserData    = errors.New("username is required and password must be at least 8 characters and at most 72 bytes")
+	// Basic credentials end the username at the first colon
+	ErrInvalidUsername = errors.New("username must not contain a colon")
+)
+
+// dummyHash is compared against when a username does not exist so that
+// unknown users take as long to reject as wrong passwords.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("blogmanager-dummy-password"), bcrypt.DefaultCost)
+
+type UserService struct {
+	UserRepo *repository.UserRepository
+}
+
+func NewUserService(userRepo *repository.UserRepository) *UserService {
+	return &UserService{UserRepo: userRepo}
+}
+
+// RegisterUser validates the credentials, hashes the password and stores the
+// user. Registered users are never admins.
+func (service *UserService) RegisterUser(username, password string) (*model.User, error) {
+	return service.createUser(username, password, false)
+}
+
+// CreateAdmin stores a new admin. It is meant for operators, not the API.
+func (service *UserService) CreateAdmin(username, password string) (*model.User, error) {
+	return service.createUser(username, password, true)
+}
+
+// PromoteToAdmin makes an existing user an admin.
+func (service *UserService) PromoteToAdmin(username string) (*model.User, error) {
+	user, err := service.UserRepo.GetUserByUsername(username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	if err := service.UserRepo.MakeAdmin(user.ID); err != nil {
+		return nil, err
+	}
+	user.IsAdmin = true
+	return user, nil
+}
+
+func (service *UserService) createUser(username, password string, admin bool) (*model.User, error) {
+	if username == "" || !validPassword(password) {
+		return nil, ErrInvalidUserData
+	}
+	if strings.Contains(username, ":") {
+		return nil, ErrInvalidUsername
+	}
+
+	hash, err := HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	return service.UserRepo.CreateUser(&model.User{Username: username, PasswordHash: hash, IsAdmin: admin})
+}
+
+// Authenticate returns the user when the password matches the stored hash.
+func (service *UserService) Authenticate(username, password string) (*model.User, error) {
+	user, err := service.UserRepo.GetUserByUsername(username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+// ChangePassword replaces the user's password after re-checking the current one.
+func (service *UserService) ChangePassword(username, currentPassword, newPassword string) error {
+	if !validPassword(newPassword) {
+		return ErrInvalidUserData
+	}
+
+	user, err := service.Authenticate(username, currentPassword)
+	if err != nil {
+		return err
+	}
+
+	hash, err := HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	return service.UserRepo.UpdatePassword(user.ID, hash)
+}
+
+// HashPassword returns the bcrypt hash of a plaintext password.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %v", err)
+	}
+	return string(hash), nil
+}
+
+func validPassword(password string) bool {
+	return utf8.RuneCountInString(password) >= minPasswordLength && len(password) <= maxPasswordBytes
+}
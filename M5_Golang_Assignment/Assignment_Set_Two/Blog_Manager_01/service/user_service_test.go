@@ -0,0 +1,108 @@
+package service
+
+import (
+	"blogmanager/config/dbtest"
+	"blogmanager/repository"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRegisterUserRejectsInvalidCredentials(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		password string
+		want     error
+	}{
+		{"empty username", "", "password123", ErrInvalidUserData},
+		{"short password", "alice", "short", ErrInvalidUserData},
+		{"long password", "alice", string(make([]byte, 73)), ErrInvalidUserData},
+		{"short multibyte password", "alice", "ééééé", ErrInvalidUserData},
+		{"colon in username", "ali:ce", "password123", ErrInvalidUsername},
+		{"trailing colon", "alice:", "password123", ErrInvalidUsername},
+	}
+
+	// Invalid credentials are rejected before the repository is used
+	service := NewUserService(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := service.RegisterUser(tt.username, tt.password); !errors.Is(err, tt.want) {
+				t.Errorf("RegisterUser(%q) = %v, want %v", tt.username, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidPassword(t *testing.T) {
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{"1234567", false},
+		{"12345678", true},
+		// Eight characters, although sixteen bytes
+		{"éééééééé", true},
+		{"ééééééé", false},
+		{strings.Repeat("a", 72), true},
+		{strings.Repeat("a", 73), false},
+		// 36 characters, but past the 72 bytes bcrypt looks at
+		{strings.Repeat("é", 36) + "a", false},
+	}
+	for _, tt := range tests {
+		if got := validPassword(tt.password); got != tt.want {
+			t.Errorf("validPassword(%q) = %v, want %v", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestRegisterUserNeverGrantsAdmin(t *testing.T) {
+	userRepo := repository.NewUserRepository(dbtest.Open(t), repository.SQLite)
+	service := NewUserService(userRepo)
+
+	for _, username := range []string{"alice", "bob"} {
+		user, err := service.RegisterUser(username, "password123")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.IsAdmin {
+			t.Errorf("registered user %s is an admin", username)
+		}
+	}
+	if hasAdmin, err := userRepo.HasAdmin(); err != nil || hasAdmin {
+		t.Errorf("HasAdmin() = %v, %v after registrations, want false", hasAdmin, err)
+	}
+}
+
+func TestCreateAdminAndPromote(t *testing.T) {
+	userRepo := repository.NewUserRepository(dbtest.Open(t), repository.SQLite)
+	service := NewUserService(userRepo)
+
+	admin, err := service.CreateAdmin("root", "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !admin.IsAdmin {
+		t.Error("CreateAdmin did not create an admin")
+	}
+	if _, err := service.CreateAdmin("root", "password123"); !errors.Is(err, repository.ErrUsernameTaken) {
+		t.Errorf("CreateAdmin of a taken username = %v, want ErrUsernameTaken", err)
+	}
+
+	if _, err := service.RegisterUser("alice", "password123"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.PromoteToAdmin("alice"); err != nil {
+		t.Fatal(err)
+	}
+	alice, err := userRepo.GetUserByUsername("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !alice.IsAdmin {
+		t.Error("PromoteToAdmin did not make alice an admin")
+	}
+	if _, err := service.PromoteToAdmin("nobody"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("PromoteToAdmin of a missing user = %v, want ErrUserNotFound", err)
+	}
+}
@@ -0,0 +1,519 @@
+package settings
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Auth modes. With AuthBasic every /api route needs credentials; with
+// AuthPublicRead anonymous GET requests are served as a logged-out visitor.
+const (
+	AuthBasic      = "basic"
+	AuthPublicRead = "public-read"
+)
+
+// Config is the configuration of the blog manager. Values are taken from, in
+// increasing order of precedence: the defaults, a YAML or TOML file, BLOG_*
+// environment variables and command-line flags.
+type Config struct {
+	Database    DatabaseConfig `yaml:"database" toml:"database"`
+	Port        int            `yaml:"port" toml:"port"`
+	Server      ServerConfig   `yaml:"server" toml:"server"`
+	LogLevel    string         `yaml:"log_level" toml:"log_level"`
+	CORSOrigins []string       `yaml:"cors_origins" toml:"cors_origins"`
+	AuthMode    string         `yaml:"auth_mode" toml:"auth_mode"`
+	// TrashRetention is how long deleted blogs stay in the trash before they
+	// are purged.
+	TrashRetention Duration `yaml:"trash_retention" toml:"trash_retention"`
+	// RequireIfMatch makes blog updates, patches and deletes without an If-Match
+	// header fail with 428 Precondition Required.
+	RequireIfMatch bool        `yaml:"require_if_match" toml:"require_if_match"`
+	Media          MediaConfig `yaml:"media" toml:"media"`
+	// TrustedProxies lists the addresses or CIDR ranges of the reverse proxies
+	// whose X-Forwarded-For header gives the client IP.
+	TrustedProxies []string        `yaml:"trusted_proxies" toml:"trusted_proxies"`
+	RateLimit      RateLimitConfig `yaml:"rate_limit" toml:"rate_limit"`
+	Cache          CacheConfig     `yaml:"cache" toml:"cache"`
+}
+
+// DatabaseConfig selects the database. Path is used by the sqlite3 driver,
+// DSN by postgres.
+type DatabaseConfig struct {
+	Driver string `yaml:"driver" toml:"driver"`
+	Path   string `yaml:"path" toml:"path"`
+	DSN    string `yaml:"dsn" toml:"dsn"`
+}
+
+// ServerConfig bounds how long the HTTP server spends on a request. On
+// shutdown, in-flight requests get ShutdownTimeout to finish.
+type ServerConfig struct {
+	ReadTimeout     Duration `yaml:"read_timeout" toml:"read_timeout"`
+	WriteTimeout    Duration `yaml:"write_timeout" toml:"write_timeout"`
+	IdleTimeout     Duration `yaml:"idle_timeout" toml:"idle_timeout"`
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+}
+
+// MediaConfig sets where uploaded attachments are stored and how large they
+// may be.
+type MediaConfig struct {
+	Dir            string `yaml:"dir" toml:"dir"`
+	MaxUploadBytes int64  `yaml:"max_upload_bytes" toml:"max_upload_bytes"`
+}
+
+// CacheConfig sizes the cache of blog reads. Entries live for at most TTL; a
+// Size of 0 turns the cache off.
+type CacheConfig struct {
+	Size int      `yaml:"size" toml:"size"`
+	TTL  Duration `yaml:"ttl" toml:"ttl"`
+}
+
+// RateLimitConfig limits requests per client IP before authentication and per
+// user after it. Routes, keyed by method and path as in "POST /api/register",
+// replace both limits for a single route. Limiting state for a client is
+// dropped once it has been idle for IdleTimeout.
+type RateLimitConfig struct {
+	IP          Limit            `yaml:"ip" toml:"ip"`
+	User        Limit            `yaml:"user" toml:"user"`
+	Routes      map[string]Limit `yaml:"routes" toml:"routes"`
+	IdleTimeout Duration         `yaml:"idle_timeout" toml:"idle_timeout"`
+}
+
+// Limit allows Rate requests per second on average, in bursts of up to Burst
+// requests. A zero Rate means no limit. On the command line and in the
+// environment it is written as rate:burst, such as 10:20.
+type Limit struct {
+	Rate  float64 `yaml:"rate" toml:"rate"`
+	Burst int     `yaml:"burst" toml:"burst"`
+}
+
+func parseLimit(value string) (Limit, error) {
+	rate, burst, ok := strings.Cut(value, ":")
+	if !ok {
+		return Limit{}, fmt.Errorf("rate limit must be rate:burst, got %q", value)
+	}
+	var limit Limit
+	var err error
+	if limit.Rate, err = strconv.ParseFloat(strings.TrimSpace(rate), 64); err != nil {
+		return Limit{}, fmt.Errorf("rate limit must be rate:burst, got %q", value)
+	}
+	if limit.Burst, err = strconv.Atoi(strings.TrimSpace(burst)); err != nil {
+		return Limit{}, fmt.Errorf("rate limit must be rate:burst, got %q", value)
+	}
+	return limit, nil
+}
+
+// parseRouteLimits parses a comma-separated list of route=rate:burst.
+func parseRouteLimits(value string) (map[string]Limit, error) {
+	routes := make(map[string]Limit)
+	for _, item := range splitList(value) {
+		route, limit, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("route rate limits must be METHOD /path=rate:burst, got %q", item)
+		}
+		parsed, err := parseLimit(limit)
+		if err != nil {
+			return nil, err
+		}
+		routes[strings.TrimSpace(route)] = parsed
+	}
+	return routes, nil
+}
+
+// Duration is a time.Duration written as a string such as "15s" in config
+// files.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func Default() *Config {
+	return &Config{
+		Database: DatabaseConfig{Driver: "sqlite3", Path: "./blogs.db"},
+		Port:     8080,
+		Server: ServerConfig{
+			ReadTimeout:     Duration(15 * time.Second),
+			WriteTimeout:    Duration(30 * time.Second),
+			IdleTimeout:     Duration(60 * time.Second),
+			ShutdownTimeout: Duration(20 * time.Second),
+		},
+		LogLevel:       "info",
+		AuthMode:       AuthBasic,
+		TrashRetention: Duration(30 * 24 * time.Hour),
+		Media:          MediaConfig{Dir: "./media", MaxUploadBytes: 10 << 20},
+		RateLimit: RateLimitConfig{
+			IP:   Limit{Rate: 20, Burst: 40},
+			User: Limit{Rate: 10, Burst: 20},
+			Routes: map[string]Limit{
+				"POST /api/register": {Rate: 0.1, Burst: 5},
+			},
+			IdleTimeout: Duration(10 * time.Minute),
+		},
+		Cache: CacheConfig{Size: 1000, TTL: Duration(time.Minute)},
+	}
+}
+
+// Load builds the configuration from the command-line arguments (without the
+// program name) and the environment, and validates it. It returns the
+// arguments left after the flags, such as a subcommand.
+func Load(args []string) (*Config, []string, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("blogmanager", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("BLOG_CONFIG"), "path to a YAML or TOML config file (env BLOG_CONFIG)")
+	driver := fs.String("db-driver", "", "database driver, sqlite3 or postgres (env BLOG_DB_DRIVER)")
+	path := fs.String("db-path", "", "SQLite database file (env BLOG_DB_PATH)")
+	dsn := fs.String("db-dsn", "", "PostgreSQL connection string (env BLOG_DB_DSN)")
+	port := fs.Int("port", 0, "port to listen on (env BLOG_PORT)")
+	readTimeout := fs.Duration("read-timeout", 0, "maximum time to read a request (env BLOG_READ_TIMEOUT)")
+	writeTimeout := fs.Duration("write-timeout", 0, "maximum time to write a response (env BLOG_WRITE_TIMEOUT)")
+	idleTimeout := fs.Duration("idle-timeout", 0, "how long idle keep-alive connections stay open (env BLOG_IDLE_TIMEOUT)")
+	shutdownTimeout := fs.Duration("shutdown-timeout", 0, "how long shutdown waits for in-flight requests (env BLOG_SHUTDOWN_TIMEOUT)")
+	logLevel := fs.String("log-level", "", "debug, info, warn or error (env BLOG_LOG_LEVEL)")
+	corsOrigins := fs.String("cors-origins", "", "comma-separated origins allowed by CORS, or * (env BLOG_CORS_ORIGINS)")
+	authMode := fs.String("auth-mode", "", "basic or public-read (env BLOG_AUTH_MODE)")
+	trashRetention := fs.Duration("trash-retention", 0, "how long deleted blogs stay in the trash (env BLOG_TRASH_RETENTION)")
+	requireIfMatch := fs.Bool("require-if-match", false, "refuse blog updates, patches and deletes without If-Match (env BLOG_REQUIRE_IF_MATCH)")
+	mediaDir := fs.String("media-dir", "", "directory uploaded attachments are stored in (env BLOG_MEDIA_DIR)")
+	maxUploadBytes := fs.Int64("max-upload-bytes", 0, "largest attachment accepted, in bytes (env BLOG_MAX_UPLOAD_BYTES)")
+	trustedProxies := fs.String("trusted-proxies", "", "comma-separated reverse proxy addresses or CIDR ranges (env BLOG_TRUSTED_PROXIES)")
+	ipLimit := fs.String("rate-limit-ip", "", "requests per second and burst per client IP, as rate:burst (env BLOG_RATE_LIMIT_IP)")
+	userLimit := fs.String("rate-limit-user", "", "requests per second and burst per user, as rate:burst (env BLOG_RATE_LIMIT_USER)")
+	routeLimits := fs.String("rate-limit-routes", "", "comma-separated METHOD /path=rate:burst limits of single routes (env BLOG_RATE_LIMIT_ROUTES)")
+	cacheSize := fs.Int("cache-size", 0, "blogs and blog pages kept in the read cache, 0 to turn it off (env BLOG_CACHE_SIZE)")
+	cacheTTL := fs.Duration("cache-ttl", 0, "how long blog reads stay cached (env BLOG_CACHE_TTL)")
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	if *configFile != "" {
+		if err := cfg.loadFile(*configFile); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := cfg.loadEnv(); err != nil {
+		return nil, nil, err
+	}
+
+	var flagErr error
+	// Only flags given on the command line override the other sources
+	fs.Visit(func(f *flag.Flag) {
+		var err error
+		switch f.Name {
+		case "db-driver":
+			cfg.Database.Driver = *driver
+		case "db-path":
+			cfg.Database.Path = *path
+		case "db-dsn":
+			cfg.Database.DSN = *dsn
+		case "port":
+			cfg.Port = *port
+		case "read-timeout":
+			cfg.Server.ReadTimeout = Duration(*readTimeout)
+		case "write-timeout":
+			cfg.Server.WriteTimeout = Duration(*writeTimeout)
+		case "idle-timeout":
+			cfg.Server.IdleTimeout = Duration(*idleTimeout)
+		case "shutdown-timeout":
+			cfg.Server.ShutdownTimeout = Duration(*shutdownTimeout)
+		case "log-level":
+			cfg.LogLevel = *logLevel
+		case "cors-origins":
+			cfg.CORSOrigins = splitList(*corsOrigins)
+		case "auth-mode":
+			cfg.AuthMode = *authMode
+		case "trash-retention":
+			cfg.TrashRetention = Duration(*trashRetention)
+		case "require-if-match":
+			cfg.RequireIfMatch = *requireIfMatch
+		case "media-dir":
+			cfg.Media.Dir = *mediaDir
+		case "max-upload-bytes":
+			cfg.Media.MaxUploadBytes = *maxUploadBytes
+		case "trusted-proxies":
+			cfg.TrustedProxies = splitList(*trustedProxies)
+		case "rate-limit-ip":
+			cfg.RateLimit.IP, err = parseLimit(*ipLimit)
+		case "rate-limit-user":
+			cfg.RateLimit.User, err = parseLimit(*userLimit)
+		case "rate-limit-routes":
+			cfg.RateLimit.Routes, err = parseRouteLimits(*routeLimits)
+		case "cache-size":
+			cfg.Cache.Size = *cacheSize
+		case "cache-ttl":
+			cfg.Cache.TTL = Duration(*cacheTTL)
+		}
+		if err != nil && flagErr == nil {
+			flagErr = fmt.Errorf("-%s: %v", f.Name, err)
+		}
+	})
+	if flagErr != nil {
+		return nil, nil, flagErr
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return cfg, fs.Args(), nil
+}
+
+func (cfg *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	default:
+		return fmt.Errorf("config file %s must end in .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+	return nil
+}
+
+func (cfg *Config) loadEnv() error {
+	if v, ok := os.LookupEnv("BLOG_DB_DRIVER"); ok {
+		cfg.Database.Driver = v
+	}
+	if v, ok := os.LookupEnv("BLOG_DB_PATH"); ok {
+		cfg.Database.Path = v
+	}
+	if v, ok := os.LookupEnv("BLOG_DB_DSN"); ok {
+		cfg.Database.DSN = v
+	}
+	if v, ok := os.LookupEnv("BLOG_PORT"); ok {
+		port, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("BLOG_PORT must be a number, got %q", v)
+		}
+		cfg.Port = port
+	}
+	durations := map[string]*Duration{
+		"BLOG_READ_TIMEOUT":     &cfg.Server.ReadTimeout,
+		"BLOG_WRITE_TIMEOUT":    &cfg.Server.WriteTimeout,
+		"BLOG_IDLE_TIMEOUT":     &cfg.Server.IdleTimeout,
+		"BLOG_SHUTDOWN_TIMEOUT": &cfg.Server.ShutdownTimeout,
+		"BLOG_TRASH_RETENTION":  &cfg.TrashRetention,
+		"BLOG_CACHE_TTL":        &cfg.Cache.TTL,
+	}
+	for name, d := range durations {
+		if v, ok := os.LookupEnv(name); ok {
+			if err := d.UnmarshalText([]byte(v)); err != nil {
+				return fmt.Errorf("%s must be a duration such as 15s, got %q", name, v)
+			}
+		}
+	}
+	if v, ok := os.LookupEnv("BLOG_LOG_LEVEL"); ok {
+		cfg.LogLevel = v
+	}
+	if v, ok := os.LookupEnv("BLOG_CORS_ORIGINS"); ok {
+		cfg.CORSOrigins = splitList(v)
+	}
+	if v, ok := os.LookupEnv("BLOG_AUTH_MODE"); ok {
+		cfg.AuthMode = v
+	}
+	if v, ok := os.LookupEnv("BLOG_REQUIRE_IF_MATCH"); ok {
+		requireIfMatch, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("BLOG_REQUIRE_IF_MATCH must be true or false, got %q", v)
+		}
+		cfg.RequireIfMatch = requireIfMatch
+	}
+	if v, ok := os.LookupEnv("BLOG_MEDIA_DIR"); ok {
+		cfg.Media.Dir = v
+	}
+	if v, ok := os.LookupEnv("BLOG_MAX_UPLOAD_BYTES"); ok {
+		maxUploadBytes, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("BLOG_MAX_UPLOAD_BYTES must be a number, got %q", v)
+		}
+		cfg.Media.MaxUploadBytes = maxUploadBytes
+	}
+	if v, ok := os.LookupEnv("BLOG_CACHE_SIZE"); ok {
+		size, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("BLOG_CACHE_SIZE must be a number, got %q", v)
+		}
+		cfg.Cache.Size = size
+	}
+	if v, ok := os.LookupEnv("BLOG_TRUSTED_PROXIES"); ok {
+		cfg.TrustedProxies = splitList(v)
+	}
+	limits := map[string]*Limit{
+		"BLOG_RATE_LIMIT_IP":   &cfg.RateLimit.IP,
+		"BLOG_RATE_LIMIT_USER": &cfg.RateLimit.User,
+	}
+	for name, limit := range limits {
+		if v, ok := os.LookupEnv(name); ok {
+			parsed, err := parseLimit(v)
+			if err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+			*limit = parsed
+		}
+	}
+	if v, ok := os.LookupEnv("BLOG_RATE_LIMIT_ROUTES"); ok {
+		routes, err := parseRouteLimits(v)
+		if err != nil {
+			return fmt.Errorf("BLOG_RATE_LIMIT_ROUTES: %v", err)
+		}
+		cfg.RateLimit.Routes = routes
+	}
+	return nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Validate reports every invalid setting at once.
+func (cfg *Config) Validate() error {
+	var errs []error
+
+	switch cfg.Database.Driver {
+	case "sqlite3":
+		if cfg.Database.Path == "" {
+			errs = append(errs, errors.New("database.path is required for the sqlite3 driver"))
+		}
+	case "postgres":
+		if cfg.Database.DSN == "" {
+			errs = append(errs, errors.New("database.dsn is required for the postgres driver"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("database.driver must be sqlite3 or postgres, got %q", cfg.Database.Driver))
+	}
+
+	if cfg.Port < 1 || cfg.Port > 65535 {
+		errs = append(errs, fmt.Errorf("port must be between 1 and 65535, got %d", cfg.Port))
+	}
+
+	timeouts := []struct {
+		name  string
+		value Duration
+	}{
+		{"server.read_timeout", cfg.Server.ReadTimeout},
+		{"server.write_timeout", cfg.Server.WriteTimeout},
+		{"server.idle_timeout", cfg.Server.IdleTimeout},
+		{"server.shutdown_timeout", cfg.Server.ShutdownTimeout},
+		{"trash_retention", cfg.TrashRetention},
+		{"rate_limit.idle_timeout", cfg.RateLimit.IdleTimeout},
+	}
+	for _, t := range timeouts {
+		if t.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive, got %s", t.name, t.value))
+		}
+	}
+
+	switch cfg.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("log_level must be debug, info, warn or error, got %q", cfg.LogLevel))
+	}
+
+	for _, origin := range cfg.CORSOrigins {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			errs = append(errs, fmt.Errorf("cors_origins must be * or scheme://host[:port], got %q", origin))
+		}
+	}
+
+	if cfg.AuthMode != AuthBasic && cfg.AuthMode != AuthPublicRead {
+		errs = append(errs, fmt.Errorf("auth_mode must be %s or %s, got %q", AuthBasic, AuthPublicRead, cfg.AuthMode))
+	}
+
+	if cfg.Media.Dir == "" {
+		errs = append(errs, errors.New("media.dir is required"))
+	}
+	if cfg.Media.MaxUploadBytes <= 0 {
+		errs = append(errs, fmt.Errorf("media.max_upload_bytes must be positive, got %d", cfg.Media.MaxUploadBytes))
+	}
+
+	if cfg.Cache.Size < 0 {
+		errs = append(errs, fmt.Errorf("cache.size must not be negative, got %d", cfg.Cache.Size))
+	}
+	// The TTL only matters when the cache is on
+	if cfg.Cache.Size > 0 && cfg.Cache.TTL <= 0 {
+		errs = append(errs, fmt.Errorf("cache.ttl must be positive when the cache is on, got %s", cfg.Cache.TTL))
+	}
+
+	for _, proxy := range cfg.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			errs = append(errs, fmt.Errorf("trusted_proxies must be IP addresses or CIDR ranges, got %q", proxy))
+		}
+	}
+
+	checkLimit := func(name string, limit Limit) {
+		if limit.Rate < 0 || (limit.Rate > 0 && limit.Burst < 1) {
+			errs = append(errs, fmt.Errorf("%s needs a rate of at least 0 and a burst of at least 1, got %g:%d", name, limit.Rate, limit.Burst))
+		}
+	}
+	checkLimit("rate_limit.ip", cfg.RateLimit.IP)
+	checkLimit("rate_limit.user", cfg.RateLimit.User)
+	for _, route := range slices.Sorted(maps.Keys(cfg.RateLimit.Routes)) {
+		method, path, ok := strings.Cut(route, " ")
+		if !ok || method == "" || method != strings.ToUpper(method) || !strings.HasPrefix(path, "/") {
+			errs = append(errs, fmt.Errorf("rate_limit.routes must be keyed by METHOD /path, got %q", route))
+		}
+		checkLimit("rate_limit.routes."+route, cfg.RateLimit.Routes[route])
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// DSN is the data source name to open the configured database with.
+func (cfg *Config) DSN() string {
+	if cfg.Database.Driver == "postgres" {
+		return cfg.Database.DSN
+	}
+	// Foreign keys are off by default in SQLite; ON DELETE CASCADE needs them
+	separator := "?"
+	if strings.Contains(cfg.Database.Path, "?") {
+		separator = "&"
+	}
+	return cfg.Database.Path + separator + "_foreign_keys=on"
+}
+
+// Addr is the address the HTTP server listens on.
+func (cfg *Config) Addr() string {
+	return ":" + strconv.Itoa(cfg.Port)
+}
@@ -0,0 +1,165 @@
+package settings
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfig writes a config file named name to a temporary directory and
+// returns its path.
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	yamlFile := writeConfig(t, "blogmanager.yaml", "port: 1000\nlog_level: warn\ncache:\n  size: 10\n  ttl: 2m\n")
+	tomlFile := writeConfig(t, "blogmanager.toml", "port = 1000\nlog_level = \"warn\"\n[cache]\nsize = 10\nttl = \"2m\"\n")
+	tests := []struct {
+		name     string
+		args     []string
+		env      map[string]string
+		port     int
+		logLevel string
+		cacheTTL time.Duration
+	}{
+		{"defaults", nil, nil, 8080, "info", time.Minute},
+		{"yaml file", []string{"-config", yamlFile}, nil, 1000, "warn", 2 * time.Minute},
+		{"toml file", []string{"-config", tomlFile}, nil, 1000, "warn", 2 * time.Minute},
+		{"file from the environment", nil, map[string]string{"BLOG_CONFIG": yamlFile}, 1000, "warn", 2 * time.Minute},
+		{"environment over file", []string{"-config", yamlFile},
+			map[string]string{"BLOG_PORT": "2000", "BLOG_CACHE_TTL": "3m"}, 2000, "warn", 3 * time.Minute},
+		{"flags over environment", []string{"-config", yamlFile, "-port", "3000", "-cache-ttl", "4m"},
+			map[string]string{"BLOG_PORT": "2000", "BLOG_CACHE_TTL": "3m", "BLOG_LOG_LEVEL": "error"}, 3000, "error", 4 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			cfg, rest, err := Load(append(tt.args, "migrate", "up"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Port != tt.port || cfg.LogLevel != tt.logLevel || time.Duration(cfg.Cache.TTL) != tt.cacheTTL {
+				t.Errorf("port %d, log level %q, cache TTL %s, want %d, %q, %s",
+					cfg.Port, cfg.LogLevel, cfg.Cache.TTL, tt.port, tt.logLevel, tt.cacheTTL)
+			}
+			if strings.Join(rest, " ") != "migrate up" {
+				t.Errorf("arguments after the flags = %q, want the subcommand", rest)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsInvalidSources(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want string
+	}{
+		{"missing file", []string{"-config", filepath.Join(t.TempDir(), "missing.yaml")}, nil, "failed to read config file"},
+		{"unknown file type", []string{"-config", writeConfig(t, "blogmanager.json", "{}")}, nil, "must end in"},
+		{"malformed file", []string{"-config", writeConfig(t, "blogmanager.yaml", "port: [")}, nil, "failed to parse"},
+		{"malformed environment", nil, map[string]string{"BLOG_PORT": "http"}, "BLOG_PORT must be a number"},
+		{"malformed flag", []string{"-rate-limit-ip", "10"}, nil, "-rate-limit-ip"},
+		{"invalid value", []string{"-port", "0"}, nil, "port must be between"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			if _, _, err := Load(tt.args); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(cfg *Config)
+		// want is empty for a valid configuration
+		want []string
+	}{
+		{"defaults", func(cfg *Config) {}, nil},
+		{"postgres", func(cfg *Config) {
+			cfg.Database = DatabaseConfig{Driver: "postgres", DSN: "postgres://localhost/blogs"}
+		}, nil},
+		{"postgres without dsn", func(cfg *Config) { cfg.Database.Driver = "postgres" }, []string{"database.dsn is required"}},
+		{"sqlite without path", func(cfg *Config) { cfg.Database.Path = "" }, []string{"database.path is required"}},
+		{"unknown driver", func(cfg *Config) { cfg.Database.Driver = "mysql" }, []string{"database.driver must be"}},
+		{"port", func(cfg *Config) { cfg.Port = 70000 }, []string{"port must be between"}},
+		{"timeout", func(cfg *Config) { cfg.Server.ReadTimeout = 0 }, []string{"server.read_timeout must be positive"}},
+		{"log level", func(cfg *Config) { cfg.LogLevel = "verbose" }, []string{"log_level must be"}},
+		{"cors origin", func(cfg *Config) { cfg.CORSOrigins = []string{"*", "https://example.com", "example.com"} },
+			[]string{`got "example.com"`}},
+		{"auth mode", func(cfg *Config) { cfg.AuthMode = "oauth" }, []string{"auth_mode must be"}},
+		{"upload limit", func(cfg *Config) { cfg.Media.MaxUploadBytes = 0 }, []string{"media.max_upload_bytes must be positive"}},
+		{"trusted proxies", func(cfg *Config) { cfg.TrustedProxies = []string{"10.0.0.0/8", "::1", "proxy"} },
+			[]string{`got "proxy"`}},
+		{"cache without ttl", func(cfg *Config) { cfg.Cache.TTL = 0 }, []string{"cache.ttl must be positive"}},
+		{"no cache without ttl", func(cfg *Config) { cfg.Cache = CacheConfig{Size: 0, TTL: 0} }, nil},
+		{"negative cache size", func(cfg *Config) { cfg.Cache.Size = -1 }, []string{"cache.size must not be negative"}},
+		{"rate limit without burst", func(cfg *Config) { cfg.RateLimit.IP = Limit{Rate: 1} }, []string{"rate_limit.ip needs"}},
+		{"no rate limit", func(cfg *Config) { cfg.RateLimit.User = Limit{} }, nil},
+		{"route key", func(cfg *Config) { cfg.RateLimit.Routes = map[string]Limit{"post /x": {Rate: 1, Burst: 1}} },
+			[]string{"rate_limit.routes must be keyed by METHOD /path"}},
+		{"every error at once", func(cfg *Config) { cfg.Port = 0; cfg.LogLevel = "" },
+			[]string{"port must be between", "log_level must be"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.change(cfg)
+			err := cfg.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Errorf("Validate = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate = nil, want errors containing %q", tt.want)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate = %v, want an error containing %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDSN(t *testing.T) {
+	tests := []struct {
+		name     string
+		database DatabaseConfig
+		want     string
+	}{
+		{"sqlite", DatabaseConfig{Driver: "sqlite3", Path: "./blogs.db"}, "./blogs.db?_foreign_keys=on"},
+		{"sqlite with options", DatabaseConfig{Driver: "sqlite3", Path: "file:blogs.db?cache=shared"},
+			"file:blogs.db?cache=shared&_foreign_keys=on"},
+		{"postgres", DatabaseConfig{Driver: "postgres", Path: "./blogs.db", DSN: "postgres://localhost/blogs?sslmode=disable"},
+			"postgres://localhost/blogs?sslmode=disable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			cfg.Database = tt.database
+			if got := cfg.DSN(); got != tt.want {
+				t.Errorf("DSN = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+// Package storage keeps uploaded files on the local disk, named by the
+// SHA-256 hash of their content.
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var ErrInvalidHash = errors.New("invalid content hash")
+
+// LocalStorage stores files in Dir. Saving the same content twice keeps a
+// single copy.
+type LocalStorage struct {
+	Dir string
+}
+
+func NewLocalStorage(dir string) (*LocalStorage, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &LocalStorage{Dir: dir}, nil
+}
+
+// ValidHash reports whether hash is a lowercase hex SHA-256, which also
+// keeps it from naming a path outside Dir.
+func ValidHash(hash string) bool {
+	if len(hash) != sha256.Size*2 {
+		return false
+	}
+	for _, r := range hash {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// Save writes the content of r to Dir and returns its hash and size.
+func (storage *LocalStorage) Save(r io.Reader) (string, int64, error) {
+	tmp, err := os.CreateTemp(storage.Dir, ".upload-*")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if err != nil {
+		return "", 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", 0, err
+	}
+
+	hash := hex.EncodeToString(h.Sum(nil))
+	path := storage.path(hash)
+	if _, err := os.Stat(path); err == nil {
+		// Already stored; refresh it so RemoveUnreferenced leaves it alone
+		now := time.Now()
+		return hash, size, os.Chtimes(path, now, now)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return "", 0, err
+	}
+	return hash, size, os.Rename(tmp.Name(), path)
+}
+
+// Open opens the file with the given hash.
+func (storage *LocalStorage) Open(hash string) (*os.File, error) {
+	if !ValidHash(hash) {
+		return nil, ErrInvalidHash
+	}
+	return os.Open(storage.path(hash))
+}
+
+// RemoveUnreferenced deletes the stored files that referenced does not know
+// about and returns how many it deleted. Files younger than grace are kept,
+// as their upload may still be in progress.
+func (storage *LocalStorage) RemoveUnreferenced(referenced map[string]bool, grace time.Duration) (int, error) {
+	entries, err := os.ReadDir(storage.Dir)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		hash := entry.Name()
+		if !entry.Type().IsRegular() || !ValidHash(hash) || referenced[hash] {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < grace {
+			continue
+		}
+		if err := os.Remove(storage.path(hash)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+func (storage *LocalStorage) path(hash string) string {
+	return filepath.Join(storage.Dir, hash)
+}
@@ -2,44 +2,34 @@ package config
 
 import (
 	"database/sql"
-	"log"
+	"fmt"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
-func InitializeDatabase() (*sql.DB, error) {
-	// Open a database connection
+// OpenDatabase opens a database connection without touching the schema.
+func OpenDatabase() (*sql.DB, error) {
 	db, err := sql.Open("sqlite3", "./ecommerce.db")
 	if err != nil {
-		log.Fatal("Error opening database: ", err)
-		return nil, err
+		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
-
-	// Create tables if they do not exist
-	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS users (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		username TEXT UNIQUE,
-		password TEXT
-	);`)
-
-	if err != nil {
-		log.Fatal("Error creating users table: ", err)
-		return nil, err
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
+	return db, nil
+}
 
-	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS products (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT,
-		description TEXT,
-		price REAL,
-		stock INTEGER,
-		category_id INTEGER
-	);`)
-
+// InitializeDatabase opens the database and applies any pending migrations.
+func InitializeDatabase() (*sql.DB, error) {
+	db, err := OpenDatabase()
 	if err != nil {
-		log.Fatal("Error creating products table: ", err)
 		return nil, err
 	}
 
+	if _, err := Migrator(db).Up(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
 	return db, nil
 }
@@ -0,0 +1,20 @@
+package config
+
+import (
+	"database/sql"
+	"embed"
+	"servicekit/migrate"
+)
+
+// Migrations are numbered SQL files in the migrations directory, named
+// NNNN_name.up.sql and NNNN_name.down.sql. Databases created before
+// versioned migrations already match the initial migration, which tolerates
+// existing tables.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migrator returns the migrator of the inventory database db.
+func Migrator(db *sql.DB) *migrate.Migrator {
+	return migrate.New(db, migrationFiles, "migrations")
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+// pendingCount returns how many known migrations have not been applied.
+func pendingCount(t *testing.T, db *sql.DB) int {
+	t.Helper()
+	states, err := Migrator(db).Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending := 0
+	for _, state := range states {
+		if state.AppliedAt == nil {
+			pending++
+		}
+	}
+	return pending
+}
+
+func TestMigrations(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "ecommerce.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	migrator := Migrator(db)
+	migrations, err := migrator.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := len(migrations)
+
+	steps := []struct {
+		name        string
+		run         func() (int, error)
+		wantCount   int
+		wantPending int
+	}{
+		{"up", migrator.Up, total, 0},
+		{"up again", migrator.Up, 0, 0},
+		{"down one", func() (int, error) { return migrator.Down(1) }, 1, 1},
+		{"up after down", migrator.Up, 1, 0},
+		{"down all", func() (int, error) { return migrator.Down(total + 1) }, total, total},
+		{"down when empty", func() (int, error) { return migrator.Down(1) }, 0, total},
+		{"up from scratch", migrator.Up, total, 0},
+	}
+	for _, step := range steps {
+		count, err := step.run()
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if count != step.wantCount {
+			t.Errorf("%s: ran %d migrations, want %d", step.name, count, step.wantCount)
+		}
+		if pending := pendingCount(t, db); pending != step.wantPending {
+			t.Errorf("%s: %d migrations pending, want %d", step.name, pending, step.wantPending)
+		}
+		err = migrator.Check(context.Background())
+		if (err == nil) != (step.wantPending == 0) {
+			t.Errorf("%s: CheckMigrations = %v with %d pending", step.name, err, step.wantPending)
+		}
+	}
+}
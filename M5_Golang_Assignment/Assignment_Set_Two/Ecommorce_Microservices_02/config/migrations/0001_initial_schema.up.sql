@@ -0,0 +1,16 @@
+-- Schema as of the introduction of versioned migrations, which databases
+-- created before then already have.
+CREATE TABLE IF NOT EXISTS users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	username TEXT UNIQUE,
+	password TEXT
+);
+
+CREATE TABLE IF NOT EXISTS products (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT,
+	description TEXT,
+	price REAL,
+	stock INTEGER,
+	category_id INTEGER
+);
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimitConfig limits requests per client IP before authentication and per
+// user after it. Routes, keyed by method and path as in "POST /login",
+// replace both limits for a single route. Limiting state for a client is
+// dropped once it has been idle for IdleTimeout.
+type RateLimitConfig struct {
+	IP          Limit
+	User        Limit
+	Routes      map[string]Limit
+	IdleTimeout time.Duration
+}
+
+// Limit allows Rate requests per second on average, in bursts of up to Burst
+// requests. A zero Rate means no limit. It is written as rate:burst, such as
+// 10:20.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// LoadRateLimitConfig reads the rate limits from ECOMMERCE_RATE_LIMIT_*
+// environment variables, falling back to the defaults for unset ones.
+// ECOMMERCE_RATE_LIMIT_ROUTES is a comma-separated list of
+// METHOD /path=rate:burst.
+func LoadRateLimitConfig() (*RateLimitConfig, error) {
+	cfg := &RateLimitConfig{
+		IP:   Limit{Rate: 20, Burst: 40},
+		User: Limit{Rate: 10, Burst: 20},
+		Routes: map[string]Limit{
+			"POST /login":    {Rate: 0.2, Burst: 5},
+			"POST /register": {Rate: 0.1, Burst: 5},
+		},
+		IdleTimeout: 10 * time.Minute,
+	}
+
+	limits := map[string]*Limit{
+		"ECOMMERCE_RATE_LIMIT_IP":   &cfg.IP,
+		"ECOMMERCE_RATE_LIMIT_USER": &cfg.User,
+	}
+	for name, limit := range limits {
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		parsed, err := parseLimit(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		*limit = parsed
+	}
+
+	if v, ok := os.LookupEnv("ECOMMERCE_RATE_LIMIT_ROUTES"); ok {
+		cfg.Routes = make(map[string]Limit)
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			route, limit, ok := strings.Cut(item, "=")
+			method, path, valid := strings.Cut(strings.TrimSpace(route), " ")
+			if !ok || !valid || method != strings.ToUpper(method) || !strings.HasPrefix(path, "/") {
+				return nil, fmt.Errorf("ECOMMERCE_RATE_LIMIT_ROUTES must list METHOD /path=rate:burst, got %q", item)
+			}
+			parsed, err := parseLimit(limit)
+			if err != nil {
+				return nil, fmt.Errorf("ECOMMERCE_RATE_LIMIT_ROUTES: %v", err)
+			}
+			cfg.Routes[strings.TrimSpace(route)] = parsed
+		}
+	}
+
+	if v, ok := os.LookupEnv("ECOMMERCE_RATE_LIMIT_IDLE_TIMEOUT"); ok {
+		parsed, err := time.ParseDuration(v)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("ECOMMERCE_RATE_LIMIT_IDLE_TIMEOUT must be a positive duration such as 10m, got %q", v)
+		}
+		cfg.IdleTimeout = parsed
+	}
+	return cfg, nil
+}
+
+func parseLimit(value string) (Limit, error) {
+	rate, burst, ok := strings.Cut(value, ":")
+	if !ok {
+		return Limit{}, fmt.Errorf("rate limit must be rate:burst, got %q", value)
+	}
+	r, rateErr := strconv.ParseFloat(strings.TrimSpace(rate), 64)
+	b, burstErr := strconv.Atoi(strings.TrimSpace(burst))
+	if rateErr != nil || burstErr != nil || r < 0 || (r > 0 && b < 1) {
+		return Limit{}, fmt.Errorf("rate limit must be rate:burst with a rate of at least 0 and a burst of at least 1, got %q", value)
+	}
+	return Limit{Rate: r, Burst: b}, nil
+}
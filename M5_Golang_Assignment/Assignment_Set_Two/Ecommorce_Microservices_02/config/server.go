@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// ServerConfig bounds how long the HTTP server spends on a request. On
+// shutdown, in-flight requests get ShutdownTimeout to finish. Only the
+// reverse proxies in TrustedProxies may name the client IP with
+// X-Forwarded-For.
+type ServerConfig struct {
+	Addr            string
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration
+	TrustedProxies  []string
+}
+
+// LoadServerConfig reads the server settings from ECOMMERCE_* environment
+// variables, falling back to the defaults for unset ones.
+func LoadServerConfig() (*ServerConfig, error) {
+	cfg := &ServerConfig{
+		Addr:            ":8080",
+		ReadTimeout:     15 * time.Second,
+		WriteTimeout:    30 * time.Second,
+		IdleTimeout:     60 * time.Second,
+		ShutdownTimeout: 20 * time.Second,
+	}
+	if v, ok := os.LookupEnv("ECOMMERCE_ADDR"); ok {
+		cfg.Addr = v
+	}
+	if v, ok := os.LookupEnv("ECOMMERCE_TRUSTED_PROXIES"); ok {
+		for _, proxy := range strings.Split(v, ",") {
+			if proxy = strings.TrimSpace(proxy); proxy != "" {
+				cfg.TrustedProxies = append(cfg.TrustedProxies, proxy)
+			}
+		}
+	}
+
+	durations := map[string]*time.Duration{
+		"ECOMMERCE_READ_TIMEOUT":     &cfg.ReadTimeout,
+		"ECOMMERCE_WRITE_TIMEOUT":    &cfg.WriteTimeout,
+		"ECOMMERCE_IDLE_TIMEOUT":     &cfg.IdleTimeout,
+		"ECOMMERCE_SHUTDOWN_TIMEOUT": &cfg.ShutdownTimeout,
+	}
+	for name, d := range durations {
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		parsed, err := time.ParseDuration(v)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("%s must be a positive duration such as 15s, got %q", name, v)
+		}
+		*d = parsed
+	}
+	return cfg, nil
+}
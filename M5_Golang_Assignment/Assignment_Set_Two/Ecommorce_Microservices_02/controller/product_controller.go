@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"ecommerce-inventory/metrics"
 	"ecommerce-inventory/model"
 	"ecommerce-inventory/service"
 	"net/http"
@@ -30,6 +31,7 @@ func (controller *ProductController) AddProduct(c *gin.Context) {
 		return
 	}
 
+	metrics.ProductsAdded.Inc()
 	c.JSON(http.StatusOK, gin.H{"message": "Product added successfully"})
 }
 
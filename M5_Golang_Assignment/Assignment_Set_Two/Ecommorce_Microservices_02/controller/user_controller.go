@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"ecommerce-inventory/metrics"
 	"ecommerce-inventory/model"
 	"ecommerce-inventory/service"
 	"net/http"
@@ -50,6 +51,7 @@ func (controller *UserController) Login(c *gin.Context) {
 	// Authenticate user
 	user, err := controller.UserService.AuthenticateUser(credentials.Username, credentials.Password)
 	if err != nil {
+		metrics.LoginsFailed.Inc()
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
@@ -1,22 +1,43 @@
 package main
 
 import (
+	"context"
 	"ecommerce-inventory/config"
 	"ecommerce-inventory/controller"
+	"ecommerce-inventory/metrics"
 	"ecommerce-inventory/middleware"
 	"ecommerce-inventory/repository"
 	"ecommerce-inventory/service"
 	"log"
+	"net/http"
+	"os"
+	"servicekit/health"
+	"servicekit/ratelimit"
+	"servicekit/server"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	serverConfig, err := config.LoadServerConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// Initialize database
 	db, err := config.InitializeDatabase()
 	if err != nil {
-		log.Fatal("Failed to connect to the database:", err)
+		log.Fatal(err)
 	}
+	metrics.Registry.RegisterDB(db, "ecommerce")
 
 	// Set up repositories, services, and controllers
 	productRepo := repository.NewProductRepository(db)
@@ -27,19 +48,55 @@ func main() {
 	userService := service.NewUserService(userRepo)
 	userController := controller.NewUserController(userService)
 
+	// Readiness fails while the database is unreachable, locked or behind on
+	// migrations
+	checker := health.NewChecker(2 * time.Second)
+	checker.Add("database", db.PingContext)
+	checker.Add("migrations", func(ctx context.Context) error { return config.Migrator(db).Check(ctx) })
+
+	rateLimitConfig, err := config.LoadRateLimitConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Limit requests per client IP before authentication and per user after it
+	routeLimits := make(map[string]ratelimit.Limit, len(rateLimitConfig.Routes))
+	for route, limit := range rateLimitConfig.Routes {
+		routeLimits[route] = ratelimit.Limit(limit)
+	}
+	ipLimiter := ratelimit.NewLimiter(ratelimit.Limit(rateLimitConfig.IP), routeLimits,
+		rateLimitConfig.IdleTimeout, ratelimit.ByIP)
+	userLimiter := ratelimit.NewLimiter(ratelimit.Limit(rateLimitConfig.User), routeLimits,
+		rateLimitConfig.IdleTimeout, middleware.RateLimitByUser)
+
 	// Set up router
 	router := gin.Default()
+	if err := router.SetTrustedProxies(serverConfig.TrustedProxies); err != nil {
+		log.Fatal(err)
+	}
 
 	// Middleware for logging requests
 	router.Use(middleware.LoggingMiddleware())
 
+	// Request counts and latencies, scraped from /metrics without a token
+	router.Use(metrics.Registry.Middleware())
+	router.GET("/metrics", metrics.Registry.Handler())
+
+	// Health checks for the orchestrator, also without a token
+	router.GET("/healthz", checker.Liveness)
+	router.GET("/readyz", checker.Readiness)
+
+	// Everything below is rate limited, starting with the unauthenticated
+	// login and registration
+	router.Use(ipLimiter.Middleware())
+
 	// User routes
 	router.POST("/register", userController.Register)
 	router.POST("/login", userController.Login)
 
 	// Product routes (authentication required)
 	authorized := router.Group("/")
-	authorized.Use(middleware.AuthMiddleware()) // Middleware for authentication
+	authorized.Use(middleware.AuthMiddleware(), userLimiter.Middleware()) // Middleware for authentication and per-user limits
 	{
 		// Routes for managing products
 		authorized.POST("/product", middleware.ValidationMiddleware(), productController.AddProduct)
@@ -49,6 +106,25 @@ func main() {
 		authorized.GET("/products", productController.GetAllProducts)
 	}
 
-	// Start the server on port 8080
-	router.Run(":8080")
+	// Start the server, port 8080 unless ECOMMERCE_ADDR says otherwise
+	srv := &http.Server{
+		Addr:              serverConfig.Addr,
+		Handler:           router,
+		ReadHeaderTimeout: serverConfig.ReadTimeout,
+		ReadTimeout:       serverConfig.ReadTimeout,
+		WriteTimeout:      serverConfig.WriteTimeout,
+		IdleTimeout:       serverConfig.IdleTimeout,
+	}
+	serveErr := server.Serve(srv, serverConfig.ShutdownTimeout)
+	if serveErr != nil {
+		log.Println("Server error:", serveErr)
+	}
+
+	if err := db.Close(); err != nil {
+		log.Println("Failed to close database:", err)
+	}
+	if serveErr != nil {
+		os.Exit(1)
+	}
+	log.Println("Server stopped.")
 }
@@ -0,0 +1,26 @@
+// Package metrics holds the Prometheus metrics of the inventory service, on
+// top of the request and runtime metrics every service exports.
+package metrics
+
+import (
+	"servicekit/httpmetrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// ProductsAdded counts the products added to the inventory.
+	ProductsAdded = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "ecommerce_products_added_total",
+		Help: "Products added.",
+	})
+
+	// LoginsFailed counts rejected logins.
+	LoginsFailed = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "ecommerce_logins_failed_total",
+		Help: "Logins rejected because of invalid credentials.",
+	})
+
+	// Registry holds every metric, served on /metrics.
+	Registry = httpmetrics.NewRegistry(ProductsAdded, LoginsFailed)
+)
@@ -9,6 +9,16 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// UserKey is the gin context key under which AuthMiddleware stores the
+// username the token was issued to.
+const UserKey = "user"
+
+// CurrentUser returns the username authenticated by AuthMiddleware, or "" on
+// routes that are not behind it.
+func CurrentUser(c *gin.Context) string {
+	return c.GetString(UserKey)
+}
+
 // AuthMiddleware checks for the presence of a valid JWT token
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -29,7 +39,8 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		// Parse and validate the token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		claims := &jwt.StandardClaims{}
+		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
 			// Check if the token's signing method is correct
 			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 				return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
@@ -46,6 +57,7 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		// Token is valid, allow access
+		c.Set(UserKey, claims.Subject)
 		c.Next()
 	}
 }
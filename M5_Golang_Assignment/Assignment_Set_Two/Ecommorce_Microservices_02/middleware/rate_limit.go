@@ -0,0 +1,12 @@
+package middleware
+
+import "github.com/gin-gonic/gin"
+
+// RateLimitByUser keys requests by the user AuthMiddleware authenticated,
+// leaving anonymous requests unlimited.
+func RateLimitByUser(c *gin.Context) string {
+	if user := CurrentUser(c); user != "" {
+		return "user:" + user
+	}
+	return ""
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"ecommerce-inventory/config"
+	"errors"
+)
+
+const migrateUsage = "usage: ecommerce-inventory migrate status|up|down [steps]"
+
+// runMigrate implements the migrate subcommand: status lists the migrations,
+// up applies the pending ones and down reverts the last steps applied ones,
+// one by default.
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	db, err := config.OpenDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return config.Migrator(db).Command(args, migrateUsage)
+}
@@ -0,0 +1,97 @@
+// Package health serves the liveness and readiness endpoints used by the
+// orchestrator.
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Check reports why a dependency is not ready, or nil when it is.
+type Check func(ctx context.Context) error
+
+// CheckResult is the outcome of one readiness check.
+type CheckResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Checker runs the readiness checks, each with its own Timeout.
+type Checker struct {
+	Timeout time.Duration
+	names   []string
+	checks  []Check
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{Timeout: timeout}
+}
+
+// Add registers a readiness check under name.
+func (checker *Checker) Add(name string, check Check) {
+	checker.names = append(checker.names, name)
+	checker.checks = append(checker.checks, check)
+}
+
+// Run runs every check concurrently and reports whether all of them passed.
+func (checker *Checker) Run(ctx context.Context) (map[string]CheckResult, bool) {
+	results := make([]CheckResult, len(checker.checks))
+	var wg sync.WaitGroup
+	for i, check := range checker.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = checker.run(ctx, check)
+		}()
+	}
+	wg.Wait()
+
+	ready := true
+	byName := make(map[string]CheckResult, len(results))
+	for i, result := range results {
+		byName[checker.names[i]] = result
+		ready = ready && result.Status == "ok"
+	}
+	return byName, ready
+}
+
+// run gives up on check after the timeout even if check ignores ctx, as a
+// locked SQLite database may keep it waiting.
+func (checker *Checker) run(ctx context.Context, check Check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, checker.Timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- check(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil {
+		return CheckResult{Status: "error", Error: err.Error()}
+	}
+	return CheckResult{Status: "ok"}
+}
+
+// Liveness answers 200 as long as the process can serve requests.
+func (checker *Checker) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readiness answers 200 when every check passes and 503 otherwise, with the
+// result of each check.
+func (checker *Checker) Readiness(c *gin.Context) {
+	results, ready := checker.Run(c.Request.Context())
+	status, code := "ok", http.StatusOK
+	if !ready {
+		status, code = "unavailable", http.StatusServiceUnavailable
+	}
+	c.JSON(code, gin.H{"status": status, "checks": results})
+}
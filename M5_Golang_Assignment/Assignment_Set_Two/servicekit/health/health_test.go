@@ -0,0 +1,90 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestReadiness(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	stuck := make(chan struct{})
+	defer close(stuck)
+
+	tests := []struct {
+		name       string
+		checks     map[string]Check
+		wantCode   int
+		wantStatus map[string]string
+	}{
+		{
+			name:       "all pass",
+			checks:     map[string]Check{"database": func(context.Context) error { return nil }},
+			wantCode:   http.StatusOK,
+			wantStatus: map[string]string{"database": "ok"},
+		},
+		{
+			name: "one fails",
+			checks: map[string]Check{
+				"database":   func(context.Context) error { return nil },
+				"migrations": func(context.Context) error { return errors.New("2 migrations pending") },
+			},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: map[string]string{"database": "ok", "migrations": "error"},
+		},
+		{
+			// A check ignoring its context still gives up at the timeout
+			name:       "one hangs",
+			checks:     map[string]Check{"database": func(context.Context) error { <-stuck; return nil }},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: map[string]string{"database": "error"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := NewChecker(50 * time.Millisecond)
+			for name, check := range tt.checks {
+				checker.Add(name, check)
+			}
+			r := gin.New()
+			r.GET("/readyz", checker.Readiness)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if w.Code != tt.wantCode {
+				t.Errorf("status %d, want %d", w.Code, tt.wantCode)
+			}
+			var body struct {
+				Checks map[string]CheckResult `json:"checks"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			for name, want := range tt.wantStatus {
+				if got := body.Checks[name]; got.Status != want || (want == "error") != (got.Error != "") {
+					t.Errorf("check %s = %+v, want status %s", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLiveness(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	checker := NewChecker(time.Second)
+	checker.Add("database", func(context.Context) error { return errors.New("down") })
+	r := gin.New()
+	r.GET("/healthz", checker.Liveness)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("liveness answered %d with a failing readiness check", w.Code)
+	}
+}
@@ -0,0 +1,41 @@
+package httpmetrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestRegistryRecordsRequestsByRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	created := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_items_created_total", Help: "Items created."})
+	registry := NewRegistry(created)
+	created.Add(3)
+
+	r := gin.New()
+	r.Use(registry.Middleware())
+	r.GET("/items/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/metrics", registry.Handler())
+	for _, path := range []string{"/items/1", "/items/2", "/nowhere"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+	for _, want := range []string{
+		`http_requests_total{method="GET",route="/items/:id",status="200"} 2`,
+		`http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`http_request_duration_seconds_count{method="GET",route="/items/:id"} 2`,
+		`test_items_created_total 3`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics lack %s", want)
+		}
+	}
+}
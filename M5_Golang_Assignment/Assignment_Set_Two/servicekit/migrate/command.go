@@ -0,0 +1,55 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Command implements the migrate subcommand of a service: status lists the
+// migrations, up applies the pending ones and down reverts the last steps
+// applied ones, one by default. usage is returned for invalid arguments.
+func (m *Migrator) Command(args []string, usage string) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+
+	switch args[0] {
+	case "status":
+		states, err := m.Status()
+		if err != nil {
+			return err
+		}
+		for _, state := range states {
+			applied := "pending"
+			if state.AppliedAt != nil {
+				applied = "applied " + state.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%s\t%s\n", state.Version, state.Name, applied)
+		}
+	case "up":
+		count, err := m.Up()
+		if err != nil {
+			return err
+		}
+		fmt.Println("Applied migrations:", count)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return errors.New("steps must be a positive number")
+			}
+			steps = n
+		}
+		count, err := m.Down(steps)
+		if err != nil {
+			return err
+		}
+		fmt.Println("Reverted migrations:", count)
+	default:
+		return errors.New(usage)
+	}
+	return nil
+}
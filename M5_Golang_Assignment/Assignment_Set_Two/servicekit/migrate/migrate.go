@@ -0,0 +1,254 @@
+// Package migrate applies versioned SQL migrations and records them in the
+// schema_migrations table.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// State is a known migration and when it was applied, nil if it is still
+// pending.
+type State struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Migrator migrates DB with the numbered SQL files in the Dir directory of
+// Files, named NNNN_name.up.sql and NNNN_name.down.sql. Rebind, if set, rewrites
+// the ? placeholders of its own queries for the database; Prepare, if set,
+// runs before the schema_migrations table is created, such as to upgrade a
+// database that predates versioned migrations.
+type Migrator struct {
+	DB      *sql.DB
+	Files   fs.FS
+	Dir     string
+	Rebind  func(query string) string
+	Prepare func() error
+}
+
+func New(db *sql.DB, files fs.FS, dir string) *Migrator {
+	return &Migrator{DB: db, Files: files, Dir: dir}
+}
+
+// Load parses the migration files, sorted by version.
+func (m *Migrator) Load() ([]Migration, error) {
+	paths, err := fs.Glob(m.Files, path.Join(m.Dir, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, file := range paths {
+		name := path.Base(file)
+		match := migrationFileName.FindStringSubmatch(name)
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", name)
+		}
+		version, _ := strconv.Atoi(match[1])
+		body, err := fs.ReadFile(m.Files, file)
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(body)
+		} else {
+			migration.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+func (m *Migrator) rebind(query string) string {
+	if m.Rebind == nil {
+		return query
+	}
+	return m.Rebind(query)
+}
+
+// ensureTable creates the schema_migrations table, after Prepare.
+func (m *Migrator) ensureTable() error {
+	if m.Prepare != nil {
+		if err := m.Prepare(); err != nil {
+			return err
+		}
+	}
+	_, err := m.DB.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TEXT NOT NULL
+	);`)
+	return err
+}
+
+// applied returns the applied_at time of every applied version.
+func (m *Migrator) applied() (map[int]time.Time, error) {
+	rows, err := m.DB.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt string
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		t, err := time.Parse(time.RFC3339, appliedAt)
+		if err != nil {
+			return nil, fmt.Errorf("migration %d has invalid applied_at: %v", version, err)
+		}
+		applied[version] = t
+	}
+	return applied, rows.Err()
+}
+
+// Status lists every known migration and whether it was applied.
+func (m *Migrator) Status() ([]State, error) {
+	if err := m.ensureTable(); err != nil {
+		return nil, err
+	}
+	migrations, err := m.Load()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]State, len(migrations))
+	for i, migration := range migrations {
+		states[i].Migration = migration
+		if t, ok := applied[migration.Version]; ok {
+			states[i].AppliedAt = &t
+		}
+	}
+	return states, nil
+}
+
+// Check fails unless every known migration has been applied. Unlike Status
+// it only reads the database.
+func (m *Migrator) Check(ctx context.Context) error {
+	migrations, err := m.Load()
+	if err != nil {
+		return err
+	}
+
+	var applied int
+	if err := m.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM schema_migrations").Scan(&applied); err != nil {
+		return err
+	}
+	if pending := len(migrations) - applied; pending > 0 {
+		return fmt.Errorf("%d migrations pending", pending)
+	}
+	return nil
+}
+
+// Up applies every pending migration in order, each in its own transaction,
+// and returns how many were applied.
+func (m *Migrator) Up() (int, error) {
+	states, err := m.Status()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, state := range states {
+		if state.AppliedAt != nil {
+			continue
+		}
+		migration := state.Migration
+		tx, err := m.DB.Begin()
+		if err != nil {
+			return count, err
+		}
+		if _, err := tx.Exec(migration.Up); err != nil {
+			tx.Rollback()
+			return count, fmt.Errorf("migration %04d_%s failed: %v", migration.Version, migration.Name, err)
+		}
+		_, err = tx.Exec(m.rebind("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)"),
+			migration.Version, migration.Name, time.Now().UTC().Format(time.RFC3339))
+		if err != nil {
+			tx.Rollback()
+			return count, err
+		}
+		if err := tx.Commit(); err != nil {
+			return count, err
+		}
+		slog.Info("applied migration", "version", migration.Version, "name", migration.Name)
+		count++
+	}
+	return count, nil
+}
+
+// Down reverts the last steps applied migrations, newest first, and returns
+// how many were reverted.
+func (m *Migrator) Down(steps int) (int, error) {
+	states, err := m.Status()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(states) - 1; i >= 0 && count < steps; i-- {
+		if states[i].AppliedAt == nil {
+			continue
+		}
+		migration := states[i].Migration
+		tx, err := m.DB.Begin()
+		if err != nil {
+			return count, err
+		}
+		if _, err := tx.Exec(migration.Down); err != nil {
+			tx.Rollback()
+			return count, fmt.Errorf("reverting migration %04d_%s failed: %v", migration.Version, migration.Name, err)
+		}
+		if _, err := tx.Exec(m.rebind("DELETE FROM schema_migrations WHERE version = ?"), migration.Version); err != nil {
+			tx.Rollback()
+			return count, err
+		}
+		if err := tx.Commit(); err != nil {
+			return count, err
+		}
+		slog.Info("reverted migration", "version", migration.Version, "name", migration.Name)
+		count++
+	}
+	return count, nil
+}
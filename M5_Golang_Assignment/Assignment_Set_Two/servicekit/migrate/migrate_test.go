@@ -0,0 +1,183 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var testMigrations = fstest.MapFS{
+	"sql/0001_items.up.sql":        {Data: []byte("CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT);")},
+	"sql/0001_items.down.sql":      {Data: []byte("DROP TABLE items;")},
+	"sql/0002_item_price.up.sql":   {Data: []byte("ALTER TABLE items ADD COLUMN price INTEGER;")},
+	"sql/0002_item_price.down.sql": {Data: []byte("ALTER TABLE items DROP COLUMN price;")},
+	"sql/0003_orders.up.sql":       {Data: []byte("CREATE TABLE orders (id INTEGER PRIMARY KEY);")},
+	"sql/0003_orders.down.sql":     {Data: []byte("DROP TABLE orders;")},
+}
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// pending returns the versions of the migrations that have not been applied.
+func pending(t *testing.T, m *Migrator) []int {
+	t.Helper()
+	states, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var versions []int
+	for _, state := range states {
+		if state.AppliedAt == nil {
+			versions = append(versions, state.Version)
+		}
+	}
+	return versions
+}
+
+func TestUpAndDown(t *testing.T) {
+	m := New(openTestDB(t), testMigrations, "sql")
+	rebound := 0
+	m.Rebind = func(query string) string {
+		rebound++
+		return query
+	}
+
+	steps := []struct {
+		name        string
+		run         func() (int, error)
+		wantCount   int
+		wantPending []int
+	}{
+		{"up", m.Up, 3, nil},
+		{"up again", m.Up, 0, nil},
+		{"down one", func() (int, error) { return m.Down(1) }, 1, []int{3}},
+		{"down more than applied", func() (int, error) { return m.Down(5) }, 2, []int{1, 2, 3}},
+		{"down when empty", func() (int, error) { return m.Down(1) }, 0, []int{1, 2, 3}},
+		{"up from scratch", m.Up, 3, nil},
+	}
+	for _, step := range steps {
+		count, err := step.run()
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if count != step.wantCount {
+			t.Errorf("%s: ran %d migrations, want %d", step.name, count, step.wantCount)
+		}
+		if got := pending(t, m); !slices.Equal(got, step.wantPending) {
+			t.Errorf("%s: pending %v, want %v", step.name, got, step.wantPending)
+		}
+		err = m.Check(context.Background())
+		if (err == nil) != (len(step.wantPending) == 0) {
+			t.Errorf("%s: Check = %v with %v pending", step.name, err, step.wantPending)
+		}
+	}
+	if rebound != 9 {
+		t.Errorf("Rebind was called %d times, want once per applied or reverted migration", rebound)
+	}
+}
+
+func TestFailedMigrationIsRolledBack(t *testing.T) {
+	files := fstest.MapFS{
+		"sql/0001_items.up.sql":      testMigrations["sql/0001_items.up.sql"],
+		"sql/0001_items.down.sql":    testMigrations["sql/0001_items.down.sql"],
+		"sql/0002_broken.up.sql":     {Data: []byte("CREATE TABLE orders (id INTEGER PRIMARY KEY); CREATE TABLE nonsense (;")},
+		"sql/0002_broken.down.sql":   {Data: []byte("DROP TABLE orders;")},
+		"sql/0003_invoices.up.sql":   {Data: []byte("CREATE TABLE invoices (id INTEGER PRIMARY KEY);")},
+		"sql/0003_invoices.down.sql": {Data: []byte("DROP TABLE invoices;")},
+	}
+	db := openTestDB(t)
+	m := New(db, files, "sql")
+
+	count, err := m.Up()
+	if err == nil || !strings.Contains(err.Error(), "0002_broken") {
+		t.Fatalf("Up = %d, %v, want the broken migration to fail", count, err)
+	}
+	if count != 1 {
+		t.Errorf("applied %d migrations before the broken one, want 1", count)
+	}
+	if got := pending(t, m); !slices.Equal(got, []int{2, 3}) {
+		t.Errorf("pending %v, want [2 3]", got)
+	}
+	var tables int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'orders'").Scan(&tables); err != nil {
+		t.Fatal(err)
+	}
+	if tables != 0 {
+		t.Error("the broken migration left its orders table behind")
+	}
+}
+
+func TestPrepareRunsFirst(t *testing.T) {
+	db := openTestDB(t)
+	m := New(db, testMigrations, "sql")
+	m.Prepare = func() error {
+		// Like a database that predates migrations, already holding items
+		_, err := db.Exec("CREATE TABLE IF NOT EXISTS legacy (id INTEGER)")
+		return err
+	}
+	if _, err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	var tables int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'legacy'").Scan(&tables); err != nil {
+		t.Fatal(err)
+	}
+	if tables != 1 {
+		t.Error("Prepare did not run")
+	}
+
+	failing := errors.New("cannot upgrade")
+	m.Prepare = func() error { return failing }
+	if _, err := m.Status(); !errors.Is(err, failing) {
+		t.Errorf("Status = %v, want the error of Prepare", err)
+	}
+}
+
+func TestLoadRejectsInvalidFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   fstest.MapFS
+		wantErr string
+	}{
+		{"bad name", fstest.MapFS{"sql/items.up.sql": {}}, "invalid migration file name"},
+		{"no down", fstest.MapFS{"sql/0001_items.up.sql": {Data: []byte("SELECT 1;")}}, "needs both an up and a down file"},
+		{"two names", fstest.MapFS{
+			"sql/0001_items.up.sql":    {Data: []byte("SELECT 1;")},
+			"sql/0001_orders.down.sql": {Data: []byte("SELECT 1;")},
+		}, "has two names"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(nil, tt.files, "sql").Load()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	migrations, err := New(nil, testMigrations, "sql").Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, m := range migrations {
+		names = append(names, m.Name)
+	}
+	if want := []string{"items", "item_price", "orders"}; !slices.Equal(names, want) {
+		t.Errorf("loaded %v, want %v in order", names, want)
+	}
+}
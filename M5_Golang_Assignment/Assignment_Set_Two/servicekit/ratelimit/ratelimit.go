@@ -0,0 +1,139 @@
+// Package ratelimit limits the requests of each client with token buckets.
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Limit allows Rate requests per second on average, in bursts of up to
+// Burst requests. A zero Rate means no limit.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Limiter limits requests with token buckets. Every client has a bucket for
+// each route listed in Routes, keyed by method and path as registered with
+// gin (such as "POST /login"), and one bucket shared by all other routes,
+// limited by Default. Key names the client of a request; an empty key leaves
+// the request unlimited. Buckets that have been idle for
+// IdleTimeout and have filled up again are dropped.
+type Limiter struct {
+	Default     Limit
+	Routes      map[string]Limit
+	IdleTimeout time.Duration
+	Key         func(c *gin.Context) string
+
+	mu        sync.Mutex
+	buckets   map[bucketKey]*bucket
+	lastSweep time.Time
+}
+
+type bucketKey struct {
+	route  string
+	client string
+}
+
+type bucket struct {
+	limit  Limit
+	tokens float64
+	last   time.Time
+}
+
+func NewLimiter(defaultLimit Limit, routes map[string]Limit, idleTimeout time.Duration,
+	key func(c *gin.Context) string) *Limiter {
+	return &Limiter{
+		Default:     defaultLimit,
+		Routes:      routes,
+		IdleTimeout: idleTimeout,
+		Key:         key,
+		buckets:     make(map[bucketKey]*bucket),
+		lastSweep:   time.Now(),
+	}
+}
+
+// ByIP keys requests by client IP.
+func ByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// Middleware takes a token from the bucket of each request, answering 429
+// Too Many Requests when it is empty. Responses carry X-RateLimit-Limit, the
+// burst size, X-RateLimit-Remaining, the requests left in the burst, and
+// X-RateLimit-Reset, the seconds until the bucket is full again.
+func (limiter *Limiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		client := limiter.Key(c)
+		route := c.Request.Method + " " + c.FullPath()
+		limit, ok := limiter.Routes[route]
+		if !ok {
+			limit, route = limiter.Default, ""
+		}
+		if client == "" || limit.Rate <= 0 {
+			c.Next()
+			return
+		}
+
+		allowed, remaining, reset, retryAfter := limiter.take(bucketKey{route: route, client: client}, limit, time.Now())
+		c.Header("X-RateLimit-Limit", strconv.Itoa(limit.Burst))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		c.Header("X-RateLimit-Reset", strconv.Itoa(reset))
+		if !allowed {
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// take refills the bucket for the time since it was last used and takes a
+// token from it if it has one. Times are in whole seconds, rounded up.
+func (limiter *Limiter) take(key bucketKey, limit Limit, now time.Time) (allowed bool, remaining, reset, retryAfter int) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	limiter.sweep(now)
+
+	b, ok := limiter.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst)}
+		limiter.buckets[key] = b
+	} else {
+		b.tokens = min(b.tokens+now.Sub(b.last).Seconds()*limit.Rate, float64(limit.Burst))
+	}
+	b.limit, b.last = limit, now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		allowed = true
+	} else {
+		retryAfter = seconds((1 - b.tokens) / limit.Rate)
+	}
+	return allowed, int(b.tokens), seconds((float64(limit.Burst) - b.tokens) / limit.Rate), retryAfter
+}
+
+// sweep drops the buckets that would be full by now, as a new bucket is
+// just the same. It runs at most once every IdleTimeout.
+func (limiter *Limiter) sweep(now time.Time) {
+	if now.Sub(limiter.lastSweep) < limiter.IdleTimeout {
+		return
+	}
+	limiter.lastSweep = now
+
+	for key, b := range limiter.buckets {
+		idle := now.Sub(b.last)
+		if idle >= limiter.IdleTimeout && b.tokens+idle.Seconds()*b.limit.Rate >= float64(b.limit.Burst) {
+			delete(limiter.buckets, key)
+		}
+	}
+}
+
+func seconds(s float64) int {
+	return max(int(math.Ceil(s)), 0)
+}
@@ -0,0 +1,116 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTakeRefills(t *testing.T) {
+	limiter := NewLimiter(Limit{}, nil, time.Hour, ByIP)
+	limit := Limit{Rate: 2, Burst: 3}
+	key := bucketKey{client: "ip:10.0.0.1"}
+	start := time.Now()
+
+	steps := []struct {
+		after          time.Duration
+		wantAllowed    bool
+		wantRemaining  int
+		wantReset      int
+		wantRetryAfter int
+	}{
+		{0, true, 2, 1, 0},
+		{0, true, 1, 1, 0},
+		{0, true, 0, 2, 0},
+		{0, false, 0, 2, 1},
+		// Half a second brings back one token
+		{500 * time.Millisecond, true, 0, 2, 0},
+		// Never more than the burst
+		{time.Minute, true, 2, 1, 0},
+	}
+	now := start
+	for i, step := range steps {
+		now = now.Add(step.after)
+		allowed, remaining, reset, retryAfter := limiter.take(key, limit, now)
+		if allowed != step.wantAllowed || remaining != step.wantRemaining || reset != step.wantReset || retryAfter != step.wantRetryAfter {
+			t.Errorf("step %d: take = %v, %d, %d, %d, want %v, %d, %d, %d", i, allowed, remaining, reset, retryAfter,
+				step.wantAllowed, step.wantRemaining, step.wantReset, step.wantRetryAfter)
+		}
+	}
+}
+
+func TestSweepDropsIdleFullBuckets(t *testing.T) {
+	start := time.Now()
+	limiter := NewLimiter(Limit{}, nil, time.Minute, ByIP)
+	limiter.lastSweep = start
+	slow := Limit{Rate: 1.0 / 3600, Burst: 1}
+	fast := Limit{Rate: 10, Burst: 5}
+
+	limiter.take(bucketKey{client: "slow"}, slow, start)
+	limiter.take(bucketKey{client: "fast"}, fast, start)
+	limiter.take(bucketKey{client: "recent"}, fast, start.Add(50*time.Second))
+
+	// Before IdleTimeout has passed since the last sweep nothing is dropped
+	limiter.sweep(start.Add(59 * time.Second))
+	if len(limiter.buckets) != 3 {
+		t.Fatalf("%d buckets after an early sweep, want 3", len(limiter.buckets))
+	}
+
+	limiter.sweep(start.Add(time.Minute))
+	for client, want := range map[string]bool{"slow": true, "fast": false, "recent": true} {
+		if _, ok := limiter.buckets[bucketKey{client: client}]; ok != want {
+			t.Errorf("bucket %s kept = %v, want %v", client, ok, want)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	routes := map[string]Limit{"POST /login": {Rate: 1, Burst: 1}}
+	limiter := NewLimiter(Limit{Rate: 1, Burst: 2}, routes, time.Minute, func(c *gin.Context) string {
+		return c.GetHeader("X-Client")
+	})
+	r := gin.New()
+	r.Use(limiter.Middleware())
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.POST("/login", ok)
+	r.GET("/items", ok)
+	r.GET("/orders", ok)
+
+	request := func(method, path, client string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("X-Client", client)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := request(http.MethodPost, "/login", "a"); w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Limit") != "1" {
+		t.Errorf("first login: %d, limit %q", w.Code, w.Header().Get("X-RateLimit-Limit"))
+	}
+	w := request(http.MethodPost, "/login", "a")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" || w.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Errorf("second login: %d, Retry-After %q, remaining %q", w.Code, w.Header().Get("Retry-After"), w.Header().Get("X-RateLimit-Remaining"))
+	}
+
+	// Other routes share the default bucket, apart from the login one
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		path := []string{"/items", "/orders", "/items"}[i]
+		if w := request(http.MethodGet, path, "a"); w.Code != want {
+			t.Errorf("request %d to %s: %d, want %d", i, path, w.Code, want)
+		}
+	}
+
+	// Clients have their own buckets, and requests without a key are not limited
+	if w := request(http.MethodPost, "/login", "b"); w.Code != http.StatusOK {
+		t.Errorf("login of another client: %d", w.Code)
+	}
+	for range 3 {
+		if w := request(http.MethodPost, "/login", ""); w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Limit") != "" {
+			t.Errorf("unkeyed login: %d, limit %q", w.Code, w.Header().Get("X-RateLimit-Limit"))
+		}
+	}
+}